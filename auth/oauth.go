@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 
@@ -21,11 +22,20 @@ func NewServiceAccountFile(serviceAccountFile string) ([]byte, error) {
 	return content, nil
 }
 
+// NewServiceAccount makes the config of the Sa file. The file may have a "subject" field along with the fields
+// of the key, then the Sa acts as that user of the domain through domain-wide delegation.
 func NewServiceAccount(content []byte) (*jwt.Config, error) {
 	conf, err := google.JWTConfigFromJSON(content, "https://www.googleapis.com/auth/drive")
 	if err != nil {
 		return nil, err
 	}
+	var extra struct {
+		Subject string `json:"subject"`
+	}
+	if err := json.Unmarshal(content, &extra); err != nil {
+		return nil, err
+	}
+	conf.Subject = extra.Subject
 	return conf, nil
 }
 
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/xybydy/gdutils/config"
+	"github.com/xybydy/gdutils/logger"
+)
+
+// UsageDay returns the UTC day the daily quota of the Sa files is counted on.
+func UsageDay(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// SetUsage sets the bytes copied by each Sa on the day, which are recorded by the previous runs.
+func (s *SaFileOrganizer) SetUsage(day string, usage map[string]int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.day = day
+	s.usage = usage
+}
+
+// AddUsage adds the bytes copied by the Sa today.
+func (s *SaFileOrganizer) AddUsage(email string, bytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.usage == nil {
+		s.usage = make(map[string]int64)
+	}
+	s.usage[email] += bytes
+}
+
+// overQuota reports whether the Sa copied its daily quota, then it is exhausted until the next UTC day.
+// It must be called with the lock held.
+func (s *SaFileOrganizer) overQuota(e *saEntry, now time.Time) bool {
+	if s.usage[e.conf.Email] < config.SaDailyQuota {
+		return false
+	}
+	s.exhaust(e, now, fmt.Sprintf("copied %d bytes today", s.usage[e.conf.Email]))
+	return true
+}
+
+// exhaust keeps the Sa aside until the next UTC day. It must be called with the lock held.
+func (s *SaFileOrganizer) exhaust(e *saEntry, now time.Time, why string) {
+	e.state = saExhausted
+	e.until = nextUsageDay(now)
+	logger.Info("Sa %s %s, it is skipped until %s", e.conf.Email, why, e.until.Format(time.RFC3339))
+}
+
+// readmit makes the Sa healthy again once its cooldown or its day is over. It must be called with the lock held.
+func (s *SaFileOrganizer) readmit(e *saEntry, now time.Time) {
+	if e.state != saCooling && e.state != saExhausted || now.Before(e.until) {
+		return
+	}
+	e.state = saHealthy
+	e.failures = 0
+	logger.Info("Sa %s is eligible again", e.conf.Email)
+}
+
+// rollover clears the usage once the UTC day changes. It must be called with the lock held.
+func (s *SaFileOrganizer) rollover(now time.Time) {
+	day := UsageDay(now)
+	if day == s.day {
+		return
+	}
+	s.day = day
+	s.usage = make(map[string]int64)
+}
+
+// cooldown is how long the Sa is kept aside after the given number of rate limits in a row.
+func cooldown(failures int) time.Duration {
+	d := config.SaCooldown << (failures - 1)
+	if d > config.SaMaxCooldown || d <= 0 {
+		return config.SaMaxCooldown
+	}
+	return d
+}
+
+// nextUsageDay returns the start of the next UTC day.
+func nextUsageDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+}
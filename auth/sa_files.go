@@ -3,30 +3,92 @@ package auth
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"golang.org/x/oauth2/jwt"
 
 	"github.com/xybydy/gdutils/config"
-	"github.com/xybydy/gdutils/counter"
 	"github.com/xybydy/gdutils/logger"
+	"github.com/xybydy/gdutils/utils"
 )
 
+var ErrNoAvailableSa = errors.New("no available SA")
+
 type ServiceAccounter interface {
 	InitFiles(string) error
-	RefreshActive() error
-	UseSa() *jwt.Config
+	UseSa(ctx context.Context) (*jwt.Config, error)
 	MarkFinished(config *jwt.Config)
+	RateLimited(sa *jwt.Config, reason string)
+	Invalid(config *jwt.Config, err error)
+}
+
+const (
+	saHealthy = iota
+	// saCooling is rate limited, it is taken again after until
+	saCooling
+	// saExhausted used up its daily quota, it is taken again on the next UTC day
+	saExhausted
+	// saInvalid has credentials which do not work, it is not taken again
+	saInvalid
+)
+
+// saEntry is a Sa file of the pool. A Sa makes one request at a time.
+type saEntry struct {
+	conf  *jwt.Config
+	index int
+	// validated is set once a token is obtained for the Sa
+	validated bool
+	busy      bool
+	lastUsed  time.Time
+
+	state int
+	until time.Time
+	// failures is the number of the rate limits in a row
+	failures int
 }
 
 type SaFileOrganizer struct {
-	availableFiles [][]byte
-	// Sa files actively in use
-	activeSA    chan *jwt.Config
-	activeSANum counter.Counter
+	// mu guards the pool, cond is signalled when a Sa becomes idle
+	mu       sync.Mutex
+	cond     *sync.Cond
+	pool     []*saEntry
+	strategy Strategy
 
 	rawFilePath []string
+	// subject is the user all the Sa files act as, instead of the subject set in the files
+	subject string
+
+	// the bytes copied today by each Sa
+	day   string
+	usage map[string]int64
+}
+
+// SetSubject makes all the Sa files act as the user through domain-wide delegation, it must be set before
+// InitFiles. The subject set in the Sa files is used if it is empty.
+func (s *SaFileOrganizer) SetSubject(subject string) {
+	s.subject = subject
+}
+
+// SetStrategy sets how the Sa of the next request is picked among the idle ones, it is round robin by default.
+func (s *SaFileOrganizer) SetStrategy(strategy Strategy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.strategy = strategy
+}
+
+func (s *SaFileOrganizer) newServiceAccount(content []byte) (*jwt.Config, error) {
+	conf, err := NewServiceAccount(content)
+	if err != nil {
+		return nil, err
+	}
+	if s.subject != "" {
+		conf.Subject = s.subject
+	}
+	return conf, nil
 }
 
 func (s *SaFileOrganizer) fetchSaFiles(saLocation string) error {
@@ -45,90 +107,223 @@ func (s *SaFileOrganizer) fetchSaFiles(saLocation string) error {
 	return nil
 }
 
+// InitFiles reads the Sa files into the pool. The Sa files are validated when they are used first.
 func (s *SaFileOrganizer) InitFiles(saLocation string) error {
-	s.activeSA = make(chan *jwt.Config, config.ParallelLimit)
-
 	if err := s.fetchSaFiles(saLocation); err != nil {
 		return err
 	}
 
-	for i, f := range s.rawFilePath {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cond = sync.NewCond(&s.mu)
+	if s.strategy == nil {
+		s.strategy = newRoundRobin()
+	}
+	for _, f := range s.rawFilePath {
 		c, err := NewServiceAccountFile(f)
 		if err != nil {
 			logger.Error("%s", err)
+			continue
 		}
-		if i < config.ParallelLimit {
-			f, err := NewServiceAccount(c)
-			if err != nil {
-				logger.Error("%s", err)
-			}
-
-			q, err := f.TokenSource(context.TODO()).Token()
-			if err != nil {
-				logger.Error("%s", err)
-			}
-
-			if q.Valid() {
-				s.activeSA <- f
-				s.activeSANum.Inc()
-			}
-		} else {
-			s.availableFiles = append(s.availableFiles, c)
+		conf, err := s.newServiceAccount(c)
+		if err != nil {
+			logger.Error("%s: %s", f, err)
+			continue
 		}
+		s.pool = append(s.pool, &saEntry{conf: conf, index: len(s.pool)})
 	}
-	logger.Debug("%d of SA files marked as active ", s.activeSANum.Get())
+	logger.Debug("%d of SA files are in the pool", len(s.pool))
 	return nil
 }
 
-func (s *SaFileOrganizer) RefreshActive() error {
-	var f []byte
-	if len(s.availableFiles) == 0 {
-		// log.Println("No available SA")
-		return errors.New("no available SA")
-	}
+// Len returns the number of the Sa files in the pool, the files which cannot be read as Sa files are not counted.
+func (s *SaFileOrganizer) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pool)
+}
 
-	for len(s.activeSA) < config.ParallelLimit {
-		f, s.availableFiles = s.availableFiles[len(s.availableFiles)-1], s.availableFiles[:len(s.availableFiles)-1]
-		c, err := NewServiceAccount(f)
+// UseSa picks an idle Sa with the strategy, and waits until one is idle or ctx is done if all of them are
+// busy. The Sa files which are near their daily quota are skipped until the next UTC day.
+func (s *SaFileOrganizer) UseSa(ctx context.Context) (*jwt.Config, error) {
+	for {
+		entry, err := s.take(ctx)
 		if err != nil {
-			logger.Error("%s", err)
+			return nil, err
+		}
+		if entry.validated {
+			logger.Debug("Using Sa: %s", entry.conf.Email)
+			return entry.conf, nil
 		}
 
-		q, err := c.TokenSource(context.TODO()).Token()
+		// the token is obtained outside of the lock, the Sa is busy meanwhile
+		q, err := entry.conf.TokenSource(ctx).Token()
+		if err == nil && !q.Valid() {
+			err = errors.New("invalid token")
+		}
+		if err != nil && ctx.Err() != nil {
+			s.MarkFinished(entry.conf)
+			return nil, ctx.Err()
+		}
+		// only the rejected credentials drop the Sa, a network error or an error of the token endpoint
+		// makes it cool down
+		if err != nil && utils.IsAuthError(err) {
+			s.Invalid(entry.conf, err)
+			continue
+		}
 		if err != nil {
-			logger.Error("%s", err)
+			s.unreachable(entry, err)
+			continue
 		}
+		s.mu.Lock()
+		entry.validated = true
+		s.mu.Unlock()
+		logger.Debug("Using Sa: %s", entry.conf.Email)
+		return entry.conf, nil
+	}
+}
+
+func (s *SaFileOrganizer) take(ctx context.Context) (*saEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cond == nil {
+		return nil, ErrNoAvailableSa
+	}
 
-		if q.Valid() {
-			logger.Debug("Valid SA, adding to orchestra")
-			s.activeSA <- c
-			s.activeSANum.Inc()
-			break
+	// wakes the waiting request up once ctx is done
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.mu.Lock()
+			s.cond.Broadcast()
+			s.mu.Unlock()
+		case <-stop:
 		}
+	}()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		s.rollover(now)
+
+		var idle []*saEntry
+		var wake time.Time
+		busy := 0
+		for _, i := range s.pool {
+			s.readmit(i, now)
+			switch {
+			case i.busy:
+				busy++
+			case i.state == saCooling:
+				if wake.IsZero() || i.until.Before(wake) {
+					wake = i.until
+				}
+			case i.state != saHealthy:
+			case s.overQuota(i, now):
+			default:
+				idle = append(idle, i)
+			}
+		}
+		if len(idle) == 0 {
+			if busy == 0 && wake.IsZero() {
+				return nil, ErrNoAvailableSa
+			}
+			if !wake.IsZero() {
+				// wakes the waiting requests up once the first Sa cools down
+				timer := time.AfterFunc(wake.Sub(now), func() {
+					s.mu.Lock()
+					s.cond.Broadcast()
+					s.mu.Unlock()
+				})
+				s.cond.Wait()
+				timer.Stop()
+				continue
+			}
+			s.cond.Wait()
+			continue
+		}
+
+		infos := make([]SaInfo, len(idle))
+		for n, i := range idle {
+			infos[n] = SaInfo{Email: i.conf.Email, Index: i.index, LastUsed: i.lastUsed, BytesToday: s.usage[i.conf.Email]}
+		}
+		entry := idle[s.strategy.Pick(infos)]
+		entry.busy = true
+		entry.lastUsed = time.Now()
+		return entry, nil
 	}
-	return nil
 }
 
-// Pendingen bir tane alir aktife koyar ve return
-func (s *SaFileOrganizer) UseSa() (*jwt.Config, error) {
-	logger.Debug("Working no of SA: %d", s.activeSANum.Get())
-	if s.activeSANum.Get() < config.ParallelLimit {
-		logger.Debug("", "Adding a new sa to active list")
-		if err := s.RefreshActive(); err != nil && s.activeSANum.Get() == 0 {
-			return nil, err
+func (s *SaFileOrganizer) entry(conf *jwt.Config) *saEntry {
+	for _, i := range s.pool {
+		if i.conf == conf {
+			return i
 		}
 	}
-	sa := <-s.activeSA
-	logger.Debug("Using Sa: %s", sa.Email)
-	return sa, nil
+	return nil
 }
 
+// MarkFinished gives the Sa back once its request is done, the request did not hit a rate limit.
 func (s *SaFileOrganizer) MarkFinished(config *jwt.Config) {
-	s.activeSA <- config
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e := s.entry(config); e != nil {
+		e.busy = false
+		e.failures = 0
+	}
+	s.cond.Signal()
 	logger.Debug("Sa got back to orchestra: %s ", config.Email)
 }
 
-func (s *SaFileOrganizer) DecSa() {
-	logger.Debug("A SA went to garbage, remaining no of SA: %d", len(s.activeSA)+len(s.availableFiles))
-	s.activeSANum.Dec()
+// RateLimited gives the Sa back after it hit a rate limit, reason is the reason of the error. The Sa cools
+// down for longer after each rate limit in a row, and it is kept aside for the rest of the UTC day once its
+// daily limit is exceeded or it is rate limited too many times in a row.
+func (s *SaFileOrganizer) RateLimited(sa *jwt.Config, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.entry(sa)
+	if e == nil {
+		return
+	}
+	e.busy = false
+	e.failures++
+	now := time.Now()
+	if reason == "dailyLimitExceeded" || e.failures >= config.SaCooldownLimit {
+		s.exhaust(e, now, fmt.Sprintf("is rate limited %d times in a row (%s)", e.failures, reason))
+	} else {
+		e.state = saCooling
+		e.until = now.Add(cooldown(e.failures))
+		logger.Info("Sa %s is rate limited (%s), it cools down until %s", sa.Email, reason, e.until.Format(time.RFC3339))
+	}
+	s.cond.Broadcast()
+}
+
+// unreachable gives the Sa back when its token cannot be obtained for a temporary reason. The Sa cools down
+// like on rate limits, but it is never kept aside for the rest of the day.
+func (s *SaFileOrganizer) unreachable(e *saEntry, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.busy = false
+	e.failures++
+	e.state = saCooling
+	e.until = time.Now().Add(cooldown(e.failures))
+	logger.Error("Unable to obtain a token for Sa %s, it cools down until %s: %s", e.conf.Email, e.until.Format(time.RFC3339), err)
+	s.cond.Broadcast()
+}
+
+// Invalid drops the Sa whose credentials do not work for the rest of the run.
+func (s *SaFileOrganizer) Invalid(config *jwt.Config, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e := s.entry(config); e != nil {
+		e.busy = false
+		e.state = saInvalid
+	}
+	// the waiting requests see if there is any Sa left
+	s.cond.Broadcast()
+	logger.Error("Sa %s is invalid, it is not used anymore: %s", config.Email, err)
 }
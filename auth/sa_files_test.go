@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/oauth2/jwt"
+
+	"github.com/xybydy/gdutils/config"
+)
+
+type OrganizerSuite struct {
+	suite.Suite
+	s *SaFileOrganizer
+}
+
+// SetupTest makes a pool of two validated Sa files, so that no tokens are requested.
+func (suite *OrganizerSuite) SetupTest() {
+	suite.s = &SaFileOrganizer{strategy: newRoundRobin()}
+	suite.s.cond = sync.NewCond(&suite.s.mu)
+	for i, email := range []string{"a", "b"} {
+		suite.s.pool = append(suite.s.pool, &saEntry{conf: &jwt.Config{Email: email}, index: i, validated: true})
+	}
+}
+
+func (suite *OrganizerSuite) TestRateLimited() {
+	a, err := suite.s.UseSa(context.Background())
+	suite.Require().NoError(err)
+	suite.s.RateLimited(a, "userRateLimitExceeded")
+
+	entry := suite.s.entry(a)
+	suite.Equal(saCooling, entry.state)
+	for i := 0; i < 3; i++ {
+		b, err := suite.s.UseSa(context.Background())
+		suite.Require().NoError(err)
+		suite.Equal("b", b.Email)
+		suite.s.MarkFinished(b)
+	}
+
+	suite.s.readmit(entry, entry.until)
+	suite.Equal(saHealthy, entry.state)
+	suite.Equal(0, entry.failures)
+}
+
+func (suite *OrganizerSuite) TestCooldownLimit() {
+	a := suite.s.pool[0].conf
+	for i := 0; i < config.SaCooldownLimit; i++ {
+		suite.s.pool[0].state = saHealthy
+		suite.s.RateLimited(a, "userRateLimitExceeded")
+	}
+	suite.Equal(saExhausted, suite.s.pool[0].state)
+
+	suite.s.RateLimited(suite.s.pool[1].conf, "dailyLimitExceeded")
+	suite.Equal(saExhausted, suite.s.pool[1].state)
+	_, err := suite.s.UseSa(context.Background())
+	suite.True(errors.Is(err, ErrNoAvailableSa))
+}
+
+func (suite *OrganizerSuite) TestInvalid() {
+	suite.s.Invalid(suite.s.pool[0].conf, errors.New("disabled"))
+	suite.s.Invalid(suite.s.pool[1].conf, errors.New("disabled"))
+	_, err := suite.s.UseSa(context.Background())
+	suite.True(errors.Is(err, ErrNoAvailableSa))
+}
+
+func (suite *OrganizerSuite) TestWaitCancelled() {
+	for _, i := range suite.s.pool {
+		i.state = saCooling
+		i.until = time.Now().Add(time.Hour)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := suite.s.UseSa(ctx)
+	suite.True(errors.Is(err, context.DeadlineExceeded))
+}
+
+// TestTokenErrors checks that only the rejected credentials drop the Sa, the other token errors make it cool down.
+func (suite *OrganizerSuite) TestTokenErrors() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	suite.Require().NoError(err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		wantState int
+		wantErr   error
+	}{
+		{"server error", http.StatusServiceUnavailable, `{"error":"unavailable"}`, saCooling, context.DeadlineExceeded},
+		{"invalid grant", http.StatusBadRequest, `{"error":"invalid_grant"}`, saInvalid, ErrNoAvailableSa},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body)) //nolint:errcheck
+			}))
+			defer ts.Close()
+
+			s := &SaFileOrganizer{strategy: newRoundRobin()}
+			s.cond = sync.NewCond(&s.mu)
+			s.pool = []*saEntry{{conf: &jwt.Config{Email: "a", PrivateKey: keyPEM, TokenURL: ts.URL}}}
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			_, err := s.UseSa(ctx)
+			suite.True(errors.Is(err, tt.wantErr), err)
+			suite.Equal(tt.wantState, s.pool[0].state)
+		})
+	}
+}
+
+func (suite *OrganizerSuite) TestLenCountsValidFiles() {
+	dir := suite.T().TempDir()
+	suite.Require().NoError(os.Mkdir(filepath.Join(dir, "sa"), 0755))
+	suite.Require().NoError(ioutil.WriteFile(filepath.Join(dir, "sa", "broken.json"), []byte(`{"type":"other"}`), 0600))
+	wd, err := os.Getwd()
+	suite.Require().NoError(err)
+	suite.Require().NoError(os.Chdir(dir))
+	defer os.Chdir(wd) //nolint:errcheck
+
+	s := new(SaFileOrganizer)
+	suite.NoError(s.InitFiles("sa"))
+	suite.Equal(0, s.Len())
+}
+
+func (suite *OrganizerSuite) TestCooldown() {
+	suite.Equal(config.SaCooldown, cooldown(1))
+	suite.Equal(2*config.SaCooldown, cooldown(2))
+	suite.Equal(config.SaMaxCooldown, cooldown(100))
+}
+
+func (suite *OrganizerSuite) TestQuota() {
+	suite.s.SetUsage(UsageDay(time.Now()), map[string]int64{"a": config.SaDailyQuota})
+	for i := 0; i < 2; i++ {
+		sa, err := suite.s.UseSa(context.Background())
+		suite.Require().NoError(err)
+		suite.Equal("b", sa.Email)
+		suite.s.MarkFinished(sa)
+	}
+	suite.Equal(saExhausted, suite.s.pool[0].state)
+}
+
+func TestOrganizerSuite(t *testing.T) {
+	suite.Run(t, new(OrganizerSuite))
+}
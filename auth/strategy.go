@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/xybydy/gdutils/config"
+)
+
+// SaInfo is what the strategies know about an idle Sa.
+type SaInfo struct {
+	Email string
+	// Index is the order of the Sa file in the Sa folder
+	Index      int
+	LastUsed   time.Time
+	BytesToday int64
+}
+
+// Strategy picks the Sa to make the next request with. Pick is called with the lock of the organizer held.
+type Strategy interface {
+	// Pick returns the position of the Sa in idle, idle is never empty.
+	Pick(idle []SaInfo) int
+}
+
+// Strategies are the names of the strategies accepted by NewStrategy.
+var Strategies = []string{"round-robin", "lru", "least-bytes", "weighted-random"}
+
+func NewStrategy(name string) (Strategy, error) {
+	switch name {
+	case "round-robin":
+		return newRoundRobin(), nil
+	case "lru":
+		return leastRecentlyUsed{}, nil
+	case "least-bytes":
+		return leastBytes{}, nil
+	case "weighted-random":
+		return weightedRandom{rand.New(rand.NewSource(time.Now().UnixNano()))}, nil
+	}
+	return nil, fmt.Errorf("unknown Sa strategy %q, it must be one of %v", name, Strategies)
+}
+
+// roundRobin takes the Sa files in the order of their files, starting after the last one taken.
+type roundRobin struct {
+	last int
+}
+
+func newRoundRobin() *roundRobin {
+	return &roundRobin{last: -1}
+}
+
+func (r *roundRobin) Pick(idle []SaInfo) int {
+	next, first := -1, 0
+	for n, i := range idle {
+		if i.Index < idle[first].Index {
+			first = n
+		}
+		if i.Index > r.last && (next == -1 || i.Index < idle[next].Index) {
+			next = n
+		}
+	}
+	if next == -1 {
+		next = first
+	}
+	r.last = idle[next].Index
+	return next
+}
+
+// leastRecentlyUsed takes the Sa which has been idle the longest.
+type leastRecentlyUsed struct{}
+
+func (leastRecentlyUsed) Pick(idle []SaInfo) int {
+	pick := 0
+	for n, i := range idle {
+		if i.LastUsed.Before(idle[pick].LastUsed) {
+			pick = n
+		}
+	}
+	return pick
+}
+
+// leastBytes takes the Sa which copied the least bytes today, the least recently used one among equals.
+type leastBytes struct{}
+
+func (leastBytes) Pick(idle []SaInfo) int {
+	pick := 0
+	for n, i := range idle {
+		p := idle[pick]
+		if i.BytesToday < p.BytesToday || i.BytesToday == p.BytesToday && i.LastUsed.Before(p.LastUsed) {
+			pick = n
+		}
+	}
+	return pick
+}
+
+// weightedRandom takes a random Sa, the more of its daily quota is left the more likely it is taken.
+type weightedRandom struct {
+	rand *rand.Rand
+}
+
+func (w weightedRandom) Pick(idle []SaInfo) int {
+	weights := make([]int64, len(idle))
+	var total int64
+	for n, i := range idle {
+		weights[n] = config.SaDailyQuota - i.BytesToday
+		if weights[n] < 1 {
+			weights[n] = 1
+		}
+		total += weights[n]
+	}
+	r := w.rand.Int63n(total)
+	for n, i := range weights {
+		if r < i {
+			return n
+		}
+		r -= i
+	}
+	return len(idle) - 1
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type StrategySuite struct {
+	suite.Suite
+}
+
+func (suite *StrategySuite) TestRoundRobin() {
+	r := newRoundRobin()
+	idle := []SaInfo{{Index: 2}, {Index: 0}, {Index: 5}}
+
+	var picked []int
+	for i := 0; i < 4; i++ {
+		picked = append(picked, idle[r.Pick(idle)].Index)
+	}
+	suite.Equal([]int{0, 2, 5, 0}, picked)
+}
+
+func (suite *StrategySuite) TestLeastRecentlyUsed() {
+	now := time.Now()
+	idle := []SaInfo{{Index: 0, LastUsed: now}, {Index: 1}, {Index: 2, LastUsed: now.Add(-time.Minute)}}
+	suite.Equal(1, leastRecentlyUsed{}.Pick(idle))
+}
+
+func (suite *StrategySuite) TestLeastBytes() {
+	now := time.Now()
+	idle := []SaInfo{{Index: 0, BytesToday: 10}, {Index: 1, BytesToday: 5, LastUsed: now}, {Index: 2, BytesToday: 5}}
+	suite.Equal(2, leastBytes{}.Pick(idle))
+}
+
+func (suite *StrategySuite) TestWeightedRandom() {
+	w := weightedRandom{rand.New(rand.NewSource(1))}
+	idle := []SaInfo{{Index: 0, BytesToday: 1 << 50}, {Index: 1}}
+	for i := 0; i < 10; i++ {
+		suite.Equal(1, w.Pick(idle))
+	}
+}
+
+func (suite *StrategySuite) TestNewStrategy() {
+	for _, i := range Strategies {
+		_, err := NewStrategy(i)
+		suite.NoError(err, i)
+	}
+	_, err := NewStrategy("fastest")
+	suite.Error(err)
+}
+
+func TestStrategySuite(t *testing.T) {
+	suite.Run(t, new(StrategySuite))
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+var ErrNotLoggedIn = errors.New("not logged in")
+
+// NewUserConfig reads the OAuth client of an installed app, which is downloaded from the Google Cloud console.
+func NewUserConfig(clientFile string) (*oauth2.Config, error) {
+	content, exists, err := ReadFile(clientFile)
+	if !exists {
+		return nil, fmt.Errorf("OAuth client file %q not found", clientFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return google.ConfigFromJSON(content, "https://www.googleapis.com/auth/drive")
+}
+
+// NewUserTokenSource returns the token source of the user account which is logged in with Login.
+func NewUserTokenSource(ctx context.Context, clientFile, tokenFile string) (oauth2.TokenSource, error) {
+	content, exists, err := ReadFile(tokenFile)
+	if !exists {
+		return nil, ErrNotLoggedIn
+	}
+	if err != nil {
+		return nil, err
+	}
+	var token oauth2.Token
+	if err := json.Unmarshal(content, &token); err != nil {
+		return nil, fmt.Errorf("invalid token file %q: %w", tokenFile, err)
+	}
+
+	conf, err := NewUserConfig(clientFile)
+	if err != nil {
+		return nil, err
+	}
+	return conf.TokenSource(ctx, &token), nil
+}
+
+// Login runs the OAuth flow of installed apps. The user opens the printed url, and the authorization code
+// is received on a loopback address. The token, including the refresh token, is saved into tokenFile.
+func Login(ctx context.Context, clientFile, tokenFile string) error {
+	conf, err := NewUserConfig(clientFile)
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	conf.RedirectURL = fmt.Sprintf("http://%s/", listener.Addr())
+
+	state, err := randomState()
+	if err != nil {
+		return err
+	}
+	codes := make(chan string, 1)
+	errs := make(chan error, 1)
+	// only the first result is sent, the redirect url may be hit again by a refresh or a retry of the browser
+	var once sync.Once
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		switch {
+		case query.Get("state") != state:
+			http.Error(w, "invalid state", http.StatusBadRequest)
+		case query.Get("error") != "":
+			fmt.Fprintln(w, "Login failed, you can close this window.")
+			once.Do(func() { errs <- fmt.Errorf("login failed: %s", query.Get("error")) })
+		default:
+			fmt.Fprintln(w, "Login succeeded, you can close this window.")
+			once.Do(func() { codes <- query.Get("code") })
+		}
+	})}
+	go server.Serve(listener) //nolint:errcheck
+	defer server.Close()
+
+	url := conf.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.ApprovalForce)
+	fmt.Printf("Open the following link in your browser and allow the access:\n\n%s\n\n", url)
+
+	var code string
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-errs:
+		return err
+	case code = <-codes:
+	}
+
+	token, err := conf.Exchange(ctx, code)
+	if err != nil {
+		return err
+	}
+	if token.RefreshToken == "" {
+		return errors.New("no refresh token is returned, remove the access of the app from the account and log in again")
+	}
+	content, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	if err := mkdir(tokenFile); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(tokenFile, content, 0600)
+}
+
+func randomState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
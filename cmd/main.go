@@ -1,51 +1,74 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
 	"github.com/alecthomas/kong"
 	"go.uber.org/zap"
 
+	"github.com/xybydy/gdutils/auth"
+	"github.com/xybydy/gdutils/config"
 	"github.com/xybydy/gdutils/gd"
 
 	"github.com/xybydy/gdutils/logger"
+	"github.com/xybydy/gdutils/utils"
 )
 
 type Global struct {
-	Debug        bool `help:"Debug mode - creates log file."`
-	Update       bool `short:"u" help:"Do not use local cache, force to obtain source folder information online"`
-	NotTeamDrive bool `help:"If it is not a team drive link, you can add this parameter to improve interface query efficiency and reduce latency" short:"N"`
+	Debug         bool   `help:"Debug mode - creates log file."`
+	Update        bool   `short:"u" help:"Do not use local cache, force to obtain source folder information online"`
+	NotTeamDrive  bool   `help:"If it is not a team drive link, you can add this parameter to improve interface query efficiency and reduce latency" short:"N"`
+	MaxAge        string `help:"Folders cached longer ago than this are listed again, such as 7d or 12h. Blank keeps them until -u is used" default:"${max_age}"`
+	CheckModified bool   `help:"Check the modification time of each cached folder online before using its cached items" default:"${check_modified}"`
+	Impersonate   string `help:"Make the Sa files act as this user of the domain through domain-wide delegation, instead of the subject set in the Sa files" placeholder:"USER@DOMAIN"`
+	SaStrategy    string `help:"How the Sa of each request is picked: round-robin, lru (least recently used), least-bytes (least bytes copied today) or weighted-random (by the quota left today)" enum:"round-robin,lru,least-bytes,weighted-random" default:"${sa_strategy}"`
 	// ServiceAccount bool `help:"Specify the service account for operation, provided that the json authorization file must be placed in the /sa Folder, please ensure that the SA account has Proper permissions。" short:"S" optional`
 }
 
 type CopyCmd struct {
-	From string `arg name:"source id" help:"ID of source google folder"`
-	To   string `arg name:"destination id" help:"ID of destination google folder"`
+	From string `arg:"" name:"source id" help:"ID of source google folder"`
+	To   string `arg:"" name:"destination id" help:"ID of destination google folder"`
 	Name string `help:"Rename the target folder, leave the original folder name blank" short:"n"`
 	Size int64  `help:"If it is not a team drive link, you can add this parameter to improve interface query efficiency and reduce latency" short:"s"`
 	DNCR bool   `short:"D" help:"do not create new root, Does not create a folder with the same name at the destination, will directly copy the files in the source folder to the destination folder as they are"`
 	File bool   `help:"Copy a single file" short:"f"`
-	Yes  bool   `help:"If a copy record is found, resume without asking" short:"y"`
+	Yes  bool   `help:"If a copy record is found, resume without asking. A lock left by a dead process is taken over as well" short:"y"`
+
+	Incremental bool   `help:"Skip the files which already exist at the destination, copy only new or changed files" short:"i"`
+	Match       string `help:"How the files at the destination are matched on incremental copies, name (name and size) or md5" enum:"name,md5" default:"name"`
 }
 
-func (c *CopyCmd) Run(g *Global) error {
+func (c *CopyCmd) Run(ctx context.Context, g *Global) error {
 	gd.InitApp()
-	_, err := gd.Copy(c.From, c.To, c.Name, c.Size, g.Update, g.NotTeamDrive, c.DNCR)
+	if err := resolveIDs(&c.From, &c.To); err != nil {
+		return err
+	}
+	_, err := gd.Copy(ctx, c.From, c.To, c.Name, c.Size, g.Update, g.NotTeamDrive, c.DNCR, c.Yes, c.Incremental, c.Match)
 	logger.Error("", err)
-	return nil
+	return err
 }
 
 type CountCmd struct {
-	ID string `arg name:"Folder ID"`
+	ID string `arg:"" name:"Folder ID"`
 
 	Sort   string `short:"s" help:"Sorting method of statistical results，Optional value name or size，If it is not filled in, it will be arranged in reverse order according to the number of files by default"`
 	Type   string `short:"t" help:"The output type of the statistical result, the optional value is html/tree/snap/json/all, all means output the data as a json, it is best to use with -o. If not filled, the command line form will be output by default"`
 	Output string `short:"o" help:"Statistics output file, suitable to use with -t'"`
 }
 
-func (c *CountCmd) Run(g *Global) error {
+func (c *CountCmd) Run(ctx context.Context, g *Global) error {
 	gd.InitApp()
-	err := gd.Count(c.ID, c.Sort, c.Type, c.Output, g.Update, g.NotTeamDrive)
+	if err := resolveIDs(&c.ID); err != nil {
+		return err
+	}
+	err := gd.Count(ctx, c.ID, c.Sort, c.Type, c.Output, g.Update, g.NotTeamDrive)
 	logger.Error("", err)
-	return nil
+	return err
 }
 
 func (c *CountCmd) Help() string {
@@ -60,36 +83,489 @@ Usage Examples:
 			Get the statistics information of the root directory of the personal drive. The results are output in JSON format, sorted by file extension, and saved to the out.json file in this directory
 	- "gdutils count FOLDERID -t all -o all.json" 
 			Get the statistics of the root  Folder of the personal drive, output all file information (including folders) in JSON format, and save it to the all.json file in this Folder
+	- "gdutils count FOLDERID -t tree -s size" 
+			Print the folder hierarchy with the number of files and the total size of each folder, bigger ones first
+	- "gdutils count FOLDERID -t snap -o snap.json" 
+			Save a snapshot of the folder to snap.json, which can be compared later with "gdutils diff snap.json FOLDERID"
 `
 }
 
 type DeDupeCmd struct {
-	ID string `arg name:"Folder ID"`
+	ID string `arg:"" name:"Folder ID"`
 
 	Yes bool `help:"If duplicate items are found, delete them without asking" short:"y"`
 }
 
-func (c *DeDupeCmd) Run() error {
-	return nil
+func (c *DeDupeCmd) Run(ctx context.Context, g *Global) error {
+	gd.InitApp()
+	if err := resolveIDs(&c.ID); err != nil {
+		return err
+	}
+	err := gd.Dedupe(ctx, c.ID, c.Yes, g.NotTeamDrive)
+	logger.Error("", err)
+	return err
+}
+
+func (c *DeDupeCmd) Help() string {
+	return `
+
+Usage Examples: 
+	- "gdutils dedupe FOLDERID" 
+			Find the files having the same md5 and size, and the empty folders in FOLDERID, then trash them after confirmation
+	- "gdutils dedupe FOLDERID -y" 
+			Trash the duplicate files and empty folders without asking
+`
 }
 
 type Md5Cmd struct {
-	ID string `arg name:"Folder ID"`
+	Lookup Md5LookupCmd `cmd:"" help:"List the IDs of the recorded files having the given md5"`
+	ID     Md5FolderCmd `arg:"" help:"Record the md5 of the files in the folder"`
+}
+
+type Md5FolderCmd struct {
+	ID string `arg:"" name:"id" help:"Folder ID"`
 
 	Size string `help:"Don't fill in the md5 records that store all files by default. If this value is set, files smaller than this size will be filtered out, which must end with b, such as 10mb" short:"s"`
 }
 
-func (c *Md5Cmd) Run() error {
+func (c *Md5FolderCmd) Run(ctx context.Context, g *Global) error {
+	gd.InitApp()
+	if err := resolveIDs(&c.ID); err != nil {
+		return err
+	}
+	err := gd.Md5(ctx, c.ID, c.Size, g.Update, g.NotTeamDrive)
+	logger.Error("", err)
+	return err
+}
+
+type Md5LookupCmd struct {
+	Hash string `arg:"" name:"hash" help:"md5 of the file"`
+}
+
+func (c *Md5LookupCmd) Run() error {
+	gd.InitDB()
+	err := gd.Md5Lookup(c.Hash)
+	logger.Error("", err)
+	return err
+}
+
+func (c *Md5Cmd) Help() string {
+	return `
+
+Usage Examples: 
+	- "gdutils md5 FOLDERID --size 10mb" 
+			Record the md5 of the files in FOLDERID which are bigger than 10mb
+	- "gdutils md5 lookup HASH" 
+			List the IDs of the recorded files having the md5 HASH
+`
+}
+
+type SyncCmd struct {
+	From string `arg:"" name:"source id" help:"ID of source google folder"`
+	To   string `arg:"" name:"destination id" help:"ID of destination google folder"`
+
+	DryRun bool `help:"Print the operations without applying them"`
+	Yes    bool `help:"Trash the extraneous items at the destination without asking. A lock left by a dead process is taken over as well" short:"y"`
+}
+
+func (c *SyncCmd) Run(ctx context.Context, g *Global) error {
+	gd.InitApp()
+	if err := resolveIDs(&c.From, &c.To); err != nil {
+		return err
+	}
+	err := gd.Sync(ctx, c.From, c.To, g.NotTeamDrive, c.DryRun, c.Yes)
+	logger.Error("", err)
+	return err
+}
+
+func (c *SyncCmd) Help() string {
+	return `
+
+Usage Examples: 
+	- "gdutils sync SOURCEID DESTID --dry-run" 
+			Print the folders to be created, the files to be copied or updated and the items to be trashed at DESTID
+	- "gdutils sync SOURCEID DESTID -y" 
+			Make DESTID identical to SOURCEID, trashing the items which do not exist in SOURCEID without asking
+`
+}
+
+type VerifyCmd struct {
+	From string `arg:"" name:"source id" help:"ID of source google folder"`
+	To   string `arg:"" name:"destination id" help:"ID of the copied google folder"`
+
+	Type   string `short:"t" help:"The output type of the report, the optional value is table/json/csv" enum:"table,json,csv" default:"table"`
+	Output string `short:"o" help:"Report output file, suitable to use with -t"`
+}
+
+func (c *VerifyCmd) Run(ctx context.Context, g *Global) error {
+	gd.InitApp()
+	if err := resolveIDs(&c.From, &c.To); err != nil {
+		return err
+	}
+	err := gd.Verify(ctx, c.From, c.To, c.Type, c.Output, g.Update, g.NotTeamDrive)
+	logger.Error("", err)
+	return err
+}
+
+func (c *VerifyCmd) Help() string {
+	return `
+
+Usage Examples: 
+	- "gdutils verify SOURCEID DESTID" 
+			Compare SOURCEID with its copy DESTID, exits with non-zero code if they differ
+	- "gdutils verify SOURCEID DESTID -t csv -o report.csv" 
+			Save the missing, extra, size mismatched and md5 mismatched items to report.csv
+`
+}
+
+type DiffCmd struct {
+	A string `arg:"" name:"folder id" help:"ID of the first google folder or a snapshot file"`
+	B string `arg:"" optional:"" name:"other folder id" help:"ID of the second google folder or a snapshot file, leave blank to compare the cached state of the first one with its current state"`
+}
+
+func (c *DiffCmd) Run(ctx context.Context, g *Global) error {
+	gd.InitApp()
+	// the snapshot files are not shadowed by the bookmarks having the same name
+	for _, i := range []*string{&c.A, &c.B} {
+		if info, err := os.Stat(*i); err == nil && !info.IsDir() {
+			continue
+		}
+		if err := resolveIDs(i); err != nil {
+			return err
+		}
+	}
+	err := gd.Diff(ctx, c.A, c.B, g.Update, g.NotTeamDrive)
+	logger.Error("", err)
+	return err
+}
+
+func (c *DiffCmd) Help() string {
+	return `
+
+Usage Examples: 
+	- "gdutils diff FOLDERID OTHERID" 
+			List the added, removed, renamed (same md5, different path) and modified files between FOLDERID and OTHERID
+	- "gdutils diff FOLDERID" 
+			List what changed in FOLDERID since it was cached, then refresh the cache
+	- "gdutils diff snap.json FOLDERID" 
+			List what changed in FOLDERID since snap.json was created with "gdutils count FOLDERID -t snap -o snap.json"
+`
+}
+
+type DuCmd struct {
+	ID string `arg:"" name:"Folder ID"`
+
+	Depth  int    `short:"d" help:"Show the folders up to this depth, --depth=-1 for all" default:"1"`
+	Top    int    `help:"Show only the first N folders after sorting, 0 for all" default:"0"`
+	Sort   string `short:"s" help:"Sorting method of the folders, the optional value is size/count/name" enum:"size,count,name" default:"size"`
+	Type   string `short:"t" help:"The output type, the optional value is table/html" enum:"table,html" default:"table"`
+	Output string `short:"o" help:"Output file, suitable to use with -t"`
+}
+
+func (c *DuCmd) Run(ctx context.Context, g *Global) error {
+	gd.InitApp()
+	if err := resolveIDs(&c.ID); err != nil {
+		return err
+	}
+	err := gd.Du(ctx, c.ID, c.Sort, c.Type, c.Output, c.Depth, c.Top, g.Update, g.NotTeamDrive)
+	logger.Error("", err)
+	return err
+}
+
+func (c *DuCmd) Help() string {
+	return `
+
+Usage Examples: 
+	- "gdutils du FOLDERID" 
+			Show the total size and the number of files of each sub folder of FOLDERID, biggest first
+	- "gdutils du FOLDERID -d 3 --top 20" 
+			Show the 20 biggest folders up to 3 levels deep
+	- "gdutils du FOLDERID --depth=-1 -t html -o du.html" 
+			Save the usage of all the folders as an HTML report
+`
+}
+
+type TaskCmd struct {
+	Ls          TaskLsCmd          `cmd:"" help:"List the copy tasks"`
+	Show        TaskShowCmd        `cmd:"" help:"Show the details of a task"`
+	Resume      TaskResumeCmd      `cmd:"" help:"Continue a task from where it is left"`
+	RetryFailed TaskRetryFailedCmd `cmd:"" help:"Copy again only the files of a task which have failed"`
+	Rm          TaskRmCmd          `cmd:"" help:"Remove a task and its copied records"`
+}
+
+type TaskLsCmd struct{}
+
+func (c *TaskLsCmd) Run() error {
+	gd.InitDB()
+	err := gd.TaskList()
+	logger.Error("", err)
+	return err
+}
+
+type TaskShowCmd struct {
+	ID int `arg:"" name:"id" help:"Task ID"`
+}
+
+func (c *TaskShowCmd) Run() error {
+	gd.InitDB()
+	err := gd.TaskShow(c.ID)
+	logger.Error("", err)
+	return err
+}
+
+type TaskResumeCmd struct {
+	ID int `arg:"" name:"id" help:"Task ID"`
+}
+
+func (c *TaskResumeCmd) Run(ctx context.Context, g *Global) error {
+	gd.InitApp()
+	err := gd.TaskResume(ctx, c.ID, g.Update, g.NotTeamDrive)
+	logger.Error("", err)
+	return err
+}
+
+type TaskRetryFailedCmd struct {
+	ID int `arg:"" name:"id" help:"Task ID"`
+}
+
+func (c *TaskRetryFailedCmd) Run(ctx context.Context) error {
+	gd.InitApp()
+	err := gd.TaskRetryFailed(ctx, c.ID)
+	logger.Error("", err)
+	return err
+}
+
+type TaskRmCmd struct {
+	ID int `arg:"" name:"id" help:"Task ID"`
+}
+
+func (c *TaskRmCmd) Run() error {
+	gd.InitDB()
+	err := gd.TaskRemove(c.ID)
+	logger.Error("", err)
+	return err
+}
+
+func (c *TaskCmd) Help() string {
+	return `
+
+Usage Examples: 
+	- "gdutils task ls" 
+			List the copy tasks with their status and the number of files copied
+	- "gdutils task show 3" 
+			Show the details of the task 3 along with the files that failed to copy
+	- "gdutils task resume 3" 
+			Continue the task 3, the files which are already copied are skipped
+	- "gdutils task retry-failed 3" 
+			Copy again only the files of the task 3 which have failed, the source is not listed again
+	- "gdutils task rm 3" 
+			Remove the task 3 and its copied records, the copied files are kept
+`
+}
+
+type CacheCmd struct {
+	Stats  CacheStatsCmd  `cmd:"" help:"Show the row counts and the size of the cache, and the oldest cached folders"`
+	Prune  CachePruneCmd  `cmd:"" help:"Remove the folders cached before the given age"`
+	Rm     CacheRmCmd     `cmd:"" help:"Remove a folder and all the folders under it from the cache"`
+	Export CacheExportCmd `cmd:"" help:"Write the cached tree of a folder into a file"`
+	Import CacheImportCmd `cmd:"" help:"Cache the tree written by cache export"`
+}
+
+type CacheStatsCmd struct{}
+
+func (c *CacheStatsCmd) Run() error {
+	gd.InitDB()
+	err := gd.CacheStats()
+	logger.Error("", err)
+	return err
+}
+
+type CachePruneCmd struct {
+	OlderThan string `help:"Age of the folders to remove, such as 30d or 12h" default:"30d"`
+}
+
+func (c *CachePruneCmd) Run() error {
+	age, err := utils.ParseAge(c.OlderThan)
+	if err != nil {
+		return err
+	}
+	gd.InitDB()
+	err = gd.CachePrune(age)
+	logger.Error("", err)
+	return err
+}
+
+type CacheRmCmd struct {
+	ID string `arg:"" name:"Folder ID"`
+}
+
+func (c *CacheRmCmd) Run() error {
+	gd.InitDB()
+	if err := resolveIDs(&c.ID); err != nil {
+		return err
+	}
+	err := gd.CacheRemove(c.ID)
+	logger.Error("", err)
+	return err
+}
+
+type CacheExportCmd struct {
+	ID   string `arg:"" name:"Folder ID"`
+	File string `arg:"" name:"file" help:"JSON file to write"`
+}
+
+func (c *CacheExportCmd) Run() error {
+	gd.InitDB()
+	if err := resolveIDs(&c.ID); err != nil {
+		return err
+	}
+	err := gd.CacheExport(c.ID, c.File)
+	logger.Error("", err)
+	return err
+}
+
+type CacheImportCmd struct {
+	File string `arg:"" name:"file" help:"JSON file written by cache export" type:"existingfile"`
+}
+
+func (c *CacheImportCmd) Run() error {
+	gd.InitDB()
+	err := gd.CacheImport(c.File)
+	logger.Error("", err)
+	return err
+}
+
+func (c *CacheCmd) Help() string {
+	return `
+
+Usage Examples: 
+	- "gdutils cache stats" 
+			Show the number of cached folders and items, the size of the cache and the oldest cached folders
+	- "gdutils cache prune --older-than 30d" 
+			Remove the folders which are listed more than 30 days ago, they are listed again when they are needed
+	- "gdutils cache rm FOLDERID" 
+			Remove FOLDERID and all the folders under it from the cache
+	- "gdutils cache export FOLDERID tree.json" 
+			Write the cached tree of FOLDERID into tree.json, walk it first with "gdutils count FOLDERID"
+	- "gdutils cache import tree.json" 
+			Cache the tree in tree.json, so that it is not listed again on this machine
+`
+}
+
+type BookmarkCmd struct {
+	Add BookmarkAddCmd `cmd:"" help:"Save a folder ID under a name"`
+	Ls  BookmarkLsCmd  `cmd:"" help:"List the bookmarks"`
+	Rm  BookmarkRmCmd  `cmd:"" help:"Remove a bookmark"`
+}
+
+type BookmarkAddCmd struct {
+	Name string `arg:"" name:"name" help:"Name of the bookmark"`
+	ID   string `arg:"" name:"Folder ID"`
+}
+
+func (c *BookmarkAddCmd) Run() error {
+	gd.InitDB()
+	err := gd.BookmarkAdd(c.Name, c.ID)
+	logger.Error("", err)
+	return err
+}
+
+type BookmarkLsCmd struct{}
+
+func (c *BookmarkLsCmd) Run() error {
+	gd.InitDB()
+	err := gd.BookmarkList()
+	logger.Error("", err)
+	return err
+}
+
+type BookmarkRmCmd struct {
+	Name string `arg:"" name:"name" help:"Name of the bookmark"`
+}
+
+func (c *BookmarkRmCmd) Run() error {
+	gd.InitDB()
+	err := gd.BookmarkRemove(c.Name)
+	logger.Error("", err)
+	return err
+}
+
+func (c *BookmarkCmd) Help() string {
+	return `
+
+Usage Examples: 
+	- "gdutils bookmark add movies FOLDERID" 
+			Save FOLDERID as movies, then "gdutils count movies" or "gdutils copy movies backup" can be used
+	- "gdutils bookmark ls" 
+			List the bookmarks with their folder IDs
+	- "gdutils bookmark rm movies" 
+			Remove the bookmark movies, the folder is left untouched
+`
+}
+
+type AuthCmd struct {
+	Login AuthLoginCmd `cmd:"" help:"Log a user account in, it is used when there are no Sa files"`
+}
+
+type AuthLoginCmd struct{}
+
+func (c *AuthLoginCmd) Run(ctx context.Context) error {
+	err := gd.Login(ctx)
+	logger.Error("", err)
+	return err
+}
+
+func (c *AuthCmd) Help() string {
+	return `
+
+Usage Examples: 
+	- "gdutils auth login" 
+			Open the printed link and allow the access, the token is saved to token.json. Create an OAuth client ID
+			of type Desktop app in the Google Cloud console and save it as credentials.json first
+`
+}
+
+// resolveIDs replaces the bookmark names among the arguments with the folder IDs they point to.
+func resolveIDs(ids ...*string) error {
+	for _, i := range ids {
+		id, err := gd.ResolveID(*i)
+		if err != nil {
+			return err
+		}
+		*i = id
+	}
 	return nil
 }
 
 var Cli struct {
 	Global
 
-	Copy   CopyCmd   `cmd help:"Copy the files from one folder to another"`
-	Count  CountCmd  `cmd`
-	Dedupe DeDupeCmd `cmd`
-	Md5    Md5Cmd    `cmd name:"md5"`
+	Copy   CopyCmd   `cmd:"" help:"Copy the files from one folder to another"`
+	Count  CountCmd  `cmd:""`
+	Dedupe DeDupeCmd `cmd:"" help:"Trash duplicate files and empty folders"`
+	Md5    Md5Cmd    `cmd:"" name:"md5" help:"Record and look up md5 hashes of files"`
+	Sync   SyncCmd   `cmd:"" help:"Make the destination folder identical to the source folder"`
+	Verify VerifyCmd `cmd:"" help:"Compare a source folder with its copy"`
+	Diff   DiffCmd   `cmd:"" help:"Show the differences between two folders, or between the cached and the current state of a folder"`
+	Du     DuCmd     `cmd:"" help:"Show the disk usage of the sub folders"`
+	Task   TaskCmd   `cmd:"" help:"Manage the copy tasks"`
+	Cache  CacheCmd  `cmd:"" help:"Manage the local cache of the folder listings"`
+
+	Bookmark BookmarkCmd `cmd:"" help:"Manage the names saved for folder IDs"`
+	Auth     AuthCmd     `cmd:"" help:"Use a user account instead of service accounts"`
+}
+
+// interruptContext returns a context which is cancelled on SIGINT or SIGTERM. The running requests are let
+// to finish after that, a second signal exits immediately.
+func interruptContext() context.Context {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+		fmt.Println("\nInterrupted, waiting for the running requests to finish. Press Ctrl-C again to exit immediately")
+		logger.Info("", "Interrupted by signal")
+	}()
+	return ctx
 }
 
 func main() {
@@ -97,9 +573,23 @@ func main() {
 		kong.Name("gdutils"),
 		kong.Description(`Google Drive utilities`),
 		kong.UsageOnError(),
+		kong.BindTo(interruptContext(), (*context.Context)(nil)),
+		kong.Vars{
+			"max_age":        config.CacheMaxAge,
+			"check_modified": strconv.FormatBool(config.CheckModified),
+			"sa_strategy":    config.SaStrategy,
+		},
 	)
 
-	err := ctx.Run(&Cli.Global)
+	maxAge, err := utils.ParseAge(Cli.MaxAge)
+	ctx.FatalIfErrorf(err)
+	gd.SetCachePolicy(maxAge, Cli.CheckModified)
+	gd.SaConfigs.SetSubject(Cli.Impersonate)
+	strategy, err := auth.NewStrategy(Cli.SaStrategy)
+	ctx.FatalIfErrorf(err)
+	gd.SaConfigs.SetStrategy(strategy)
+
+	err = ctx.Run(&Cli.Global)
 	zap.S().Error(err)
 	ctx.FatalIfErrorf(err)
 }
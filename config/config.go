@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 const PageSize = 1004 // Each network request reads the number of files in the directory, the larger the value, the more likely it will time out, and it must not exceed 1000
 
 const RetryLimit = 7     // If a request fails, the maximum number of retries allowed
@@ -9,4 +11,18 @@ const DefaultTarget = "" // Required, copy the default destination ID, if target
 
 const SaLocation = "sa" // flag to assign
 
+const SaDailyQuota = 735 << 30 // The Sa files which copied this many bytes today are skipped until the next UTC day, Google allows about 750GB per day
+
+const SaCooldown = time.Minute         // A rate limited Sa is not used for this long, doubled for each rate limit in a row
+const SaMaxCooldown = 30 * time.Minute // The longest a rate limited Sa is not used for
+const SaCooldownLimit = 6              // A Sa rate limited this many times in a row is not used until the next UTC day
+
+const SaStrategy = "round-robin" // How the Sa of each request is picked, round-robin, lru (least recently used), least-bytes (least bytes copied today) or weighted-random (by the quota left today)
+
+const OAuthClientFile = "credentials.json" // OAuth client of an installed app, used with "gdutils auth login" when there are no Sa files
+const TokenFile = "token.json"             // Token of the user account saved by "gdutils auth login"
+
+const CacheMaxAge = ""      // The cached folders listed longer ago than this are listed again, such as 7d or 12h, leave blank to keep them until -u is used
+const CheckModified = false // Compare the modifiedTime of each cached folder with the time it is listed before using its cached items
+
 const DBPath = "gdurl.sqlite"
\ No newline at end of file
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+)
+
+func (d *DriveDB) BookmarkGet(alias string) (BookmarkDB, bool, error) {
+	var bookmark BookmarkDB
+	err := d.Get(&bookmark, "SELECT * FROM bookmark WHERE alias=?", alias)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return bookmark, false, nil
+		}
+		return bookmark, false, err
+	}
+	return bookmark, true, nil
+}
+
+func (d *DriveDB) BookmarkList() ([]BookmarkDB, error) {
+	var bookmarks []BookmarkDB
+	err := d.Select(&bookmarks, "SELECT * FROM bookmark ORDER BY alias")
+	return bookmarks, err
+}
+
+func (d *DriveDB) BookmarkInsert(alias, target string) error {
+	_, err := d.Exec("INSERT INTO bookmark (alias, target) VALUES (?, ?)", alias, target)
+	return err
+}
+
+// BookmarkDelete removes the bookmark, and reports whether it existed.
+func (d *DriveDB) BookmarkDelete(alias string) (bool, error) {
+	res, err := d.Exec("DELETE FROM bookmark WHERE alias=?", alias)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// listedAt is the time the folder is listed, the summary updates do not change the mtime of the folders.
+const listedAt = "COALESCE(mtime, ctime, 0)"
+
+func (d *DriveDB) CacheStats() (CacheStats, error) {
+	var stats CacheStats
+	err := d.Get(&stats, `SELECT
+		(SELECT COUNT(*) FROM gd) AS folders,
+		(SELECT COUNT(*) FROM files) AS files,
+		(SELECT COUNT(*) FROM hash) AS hashes,
+		(SELECT COUNT(*) FROM task) AS tasks,
+		(SELECT COUNT(*) FROM changes) AS tracked,
+		(SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()) AS size`)
+	return stats, err
+}
+
+// CacheOldest returns the cached folders which are listed the longest time ago.
+func (d *DriveDB) CacheOldest(limit int) ([]CacheEntry, error) {
+	var entries []CacheEntry
+	err := d.Select(&entries, `SELECT fid, `+listedAt+` AS listed_at, (SELECT COUNT(*) FROM files WHERE parent=gd.fid) AS items
+		FROM gd ORDER BY listed_at LIMIT ?`, limit)
+	return entries, err
+}
+
+// CacheTree returns the cached folders of the tree by following the subf lists from the root. The folders
+// which are not cached are left out along with the folders under them.
+func (d *DriveDB) CacheTree(root string) ([]GdDB, error) {
+	var folders []GdDB
+	err := d.Transaction(func(tx *sqlx.Tx) error {
+		var err error
+		folders, err = cachedTree(tx, root)
+		return err
+	})
+	return folders, err
+}
+
+// CachePrune removes the folders listed before the given time along with their items, and the items whose
+// folder is not cached. The number of the removed folders is returned.
+func (d *DriveDB) CachePrune(before int64) (int, error) {
+	var removed int
+	err := d.Transaction(func(tx *sqlx.Tx) error {
+		var fids []string
+		if err := tx.Select(&fids, "SELECT fid FROM gd WHERE "+listedAt+" < ?", before); err != nil {
+			return err
+		}
+		for _, i := range fids {
+			if err := deleteFolder(tx, i); err != nil {
+				return err
+			}
+		}
+		removed = len(fids)
+		_, err := tx.Exec("DELETE FROM files WHERE parent NOT IN (SELECT fid FROM gd)")
+		return err
+	})
+	return removed, err
+}
+
+// CacheRemove removes the cached folder and all the cached folders under it. The number of the removed
+// folders is returned.
+func (d *DriveDB) CacheRemove(root string) (int, error) {
+	var removed int
+	err := d.Transaction(func(tx *sqlx.Tx) error {
+		folders, err := cachedTree(tx, root)
+		if err != nil {
+			return err
+		}
+		for _, i := range folders {
+			if err := deleteFolder(tx, i.Fid.String); err != nil {
+				return err
+			}
+		}
+		removed = len(folders)
+		return nil
+	})
+	return removed, err
+}
+
+func cachedTree(tx *sqlx.Tx, root string) ([]GdDB, error) {
+	var folders []GdDB
+	visited := map[string]bool{root: true}
+	queue := []string{root}
+	for len(queue) > 0 {
+		fid := queue[0]
+		queue = queue[1:]
+
+		var record GdDB
+		err := tx.Get(&record, "SELECT * FROM gd WHERE fid=?", fid)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		folders = append(folders, record)
+
+		var subf GdSubf
+		if record.Subf.Valid && record.Subf.String != "" {
+			if err := json.Unmarshal([]byte(record.Subf.String), &subf); err != nil {
+				return nil, err
+			}
+		}
+		for _, i := range subf {
+			if !visited[i] {
+				visited[i] = true
+				queue = append(queue, i)
+			}
+		}
+	}
+	return folders, nil
+}
+
+func deleteFolder(tx *sqlx.Tx, fid string) error {
+	for _, query := range []string{
+		"DELETE FROM files WHERE parent=?",
+		"DELETE FROM gd WHERE fid=?",
+		"DELETE FROM changes WHERE fid=?",
+	} {
+		if _, err := tx.Exec(query, fid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func (d *DriveDB) ChangesGet(fid string) (ChangesDB, bool, error) {
+	var record ChangesDB
+	err := d.Get(&record, "SELECT * FROM changes WHERE fid=?", fid)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return record, false, nil
+		}
+		return record, false, err
+	}
+	return record, true, nil
+}
+
+func (d *DriveDB) ChangesSave(fid, driveID, token string) error {
+	_, err := d.Exec("INSERT OR REPLACE INTO changes (fid, driveid, token, mtime) VALUES (?, ?, ?, ?)", fid, driveID, token, time.Now().Unix())
+	return err
+}
+
+func (d *DriveDB) ChangesDelete(fid string) error {
+	_, err := d.Exec("DELETE FROM changes WHERE fid=?", fid)
+	return err
+}
+
+// FilesApplyChanges applies the changes to the cached files in a single transaction, and updates the sub
+// folder lists of the affected folders. The number of changed rows is returned.
+func (d *DriveDB) FilesApplyChanges(changes []FileChange) (int, error) {
+	var applied int
+	err := d.Transaction(func(tx *sqlx.Tx) error {
+		applied = 0
+		affected := make(map[string]bool)
+		for _, i := range changes {
+			var old FileDB
+			err := tx.Get(&old, "SELECT * FROM files WHERE id=?", i.ID)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			cached := err == nil
+			if cached && old.Mime == folderType {
+				affected[old.Parent] = true
+			}
+
+			keep := false
+			if !i.Removed {
+				var fid string
+				err := tx.Get(&fid, "SELECT fid FROM gd WHERE fid=?", i.File.Parent)
+				if err != nil && !errors.Is(err, sql.ErrNoRows) {
+					return err
+				}
+				keep = err == nil
+			}
+
+			switch {
+			case keep:
+				_, err := tx.NamedExec(`INSERT OR REPLACE INTO files (id, parent, name, md5, size, mime, mtime, cached_at)
+					VALUES (:id, :parent, :name, :md5, :size, :mime, :mtime, :cached_at)`, i.File)
+				if err != nil {
+					return err
+				}
+				if i.File.Mime == folderType {
+					affected[i.File.Parent] = true
+				}
+			case cached:
+				if _, err := tx.Exec("DELETE FROM files WHERE id=?", i.ID); err != nil {
+					return err
+				}
+				// the cached tree of a removed folder is not reachable anymore
+				if old.Mime == folderType {
+					folders, err := cachedTree(tx, i.ID)
+					if err != nil {
+						return err
+					}
+					for _, j := range folders {
+						if err := deleteFolder(tx, j.Fid.String); err != nil {
+							return err
+						}
+					}
+				}
+			default:
+				continue
+			}
+			applied++
+		}
+
+		for parent := range affected {
+			if err := updateSubf(tx, parent); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// updateSubf rebuilds the sub folder list of the cached folder from its cached children. The mtime is left
+// as is, it is the time the folder is listed.
+func updateSubf(tx *sqlx.Tx, fid string) error {
+	subf := make([]string, 0)
+	if err := tx.Select(&subf, "SELECT id FROM files WHERE parent=? AND mime=? ORDER BY name", fid, folderType); err != nil {
+		return err
+	}
+	subfJSON, err := json.Marshal(subf)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE gd SET subf=? WHERE fid=?", string(subfJSON), fid)
+	return err
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"database/sql"
+	"strings"
 	"sync"
 
 	"github.com/jmoiron/sqlx"
@@ -14,14 +15,27 @@ type DriveDB struct {
 	db *sqlx.DB
 }
 
+// sqliteOptions make the processes sharing the db, such as overlapping cron runs, wait for each other's
+// writes instead of failing with "database is locked".
+const sqliteOptions = "_busy_timeout=5000&_journal_mode=WAL"
+
+// ConnectDB connects to the db and applies the pending schema migrations.
 func ConnectDB(name, path string) *DriveDB {
 	var d = new(DriveDB)
-	db, err := sqlx.Connect(name, path)
+	db, err := sqlx.Connect(name, withOptions(path))
 	utils.CheckErr(err)
 	d.db = db
+	utils.CheckErr(d.Migrate())
 	return d
 }
 
+func withOptions(path string) string {
+	if strings.Contains(path, "?") {
+		return path + "&" + sqliteOptions
+	}
+	return path + "?" + sqliteOptions
+}
+
 func (d *DriveDB) lock() {
 	d.Lock()
 }
@@ -54,6 +68,24 @@ func (d *DriveDB) Select(dest interface{}, query string, args ...interface{}) er
 	return err
 }
 
+// Transaction runs fn in a transaction, which is rolled back if fn returns an error.
+func (d *DriveDB) Transaction(fn func(*sqlx.Tx) error) error {
+	d.lock()
+	defer d.unlock()
+
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return rbErr
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
 func (d *DriveDB) Close() error {
 	return d.db.Close()
 }
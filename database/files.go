@@ -0,0 +1,106 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/xybydy/gdutils/logger"
+)
+
+const folderType = "application/vnd.google-apps.folder"
+
+// treeQuery selects the ids of the folders under the given root, including the root itself.
+const treeQuery = `WITH RECURSIVE tree(id) AS (
+	SELECT ?
+	UNION
+	SELECT files.id FROM files JOIN tree ON files.parent = tree.id WHERE files.mime = ?
+)`
+
+func replaceFiles(tx *sqlx.Tx, parent string, files []FileDB) error {
+	if _, err := tx.Exec("DELETE FROM files WHERE parent=?", parent); err != nil {
+		return err
+	}
+	for _, i := range files {
+		_, err := tx.NamedExec(`INSERT OR REPLACE INTO files (id, parent, name, md5, size, mime, mtime, cached_at)
+			VALUES (:id, :parent, :name, :md5, :size, :mime, :mtime, :cached_at)`, i)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FilesReplace replaces the cached children of the parent with the given files.
+func (d *DriveDB) FilesReplace(parent string, files []FileDB) error {
+	logger.Debug("replacing %d cached files of %s", len(files), parent)
+	return d.Transaction(func(tx *sqlx.Tx) error {
+		return replaceFiles(tx, parent, files)
+	})
+}
+
+// FilesReplaceFolders caches the listings of the folders in a single transaction.
+func (d *DriveDB) FilesReplaceFolders(folders []CachedFolder) error {
+	logger.Debug("caching the listings of %d folders", len(folders))
+	return d.Transaction(func(tx *sqlx.Tx) error {
+		now := time.Now().Unix()
+		for _, i := range folders {
+			if err := replaceFiles(tx, i.Fid, i.Files); err != nil {
+				return err
+			}
+			listedAt := now
+			if i.ListedAt > 0 {
+				listedAt = i.ListedAt
+			}
+			res, err := tx.Exec("UPDATE gd SET info=NULL, subf=?, mtime=? WHERE fid=?", i.Subf, listedAt, i.Fid)
+			if err != nil {
+				return err
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				continue
+			}
+			if _, err := tx.Exec("INSERT INTO gd (fid, subf, ctime) VALUES (?, ?, ?)", i.Fid, i.Subf, listedAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (d *DriveDB) FilesGet(id string) (FileDB, bool, error) {
+	var file FileDB
+	err := d.Get(&file, "SELECT * FROM files WHERE id=?", id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return file, false, nil
+		}
+		return file, false, err
+	}
+	return file, true, nil
+}
+
+func (d *DriveDB) FilesGetChildren(parent string) ([]FileDB, error) {
+	var files []FileDB
+	err := d.Select(&files, "SELECT * FROM files WHERE parent=? ORDER BY name", parent)
+	return files, err
+}
+
+// FilesGetTree returns all the cached items under the root.
+func (d *DriveDB) FilesGetTree(root string) ([]FileDB, error) {
+	var files []FileDB
+	err := d.Select(&files, treeQuery+" SELECT files.* FROM files JOIN tree ON files.parent = tree.id", root, folderType)
+	return files, err
+}
+
+// FilesMissingFolders returns the number of folders under the root which have never been listed.
+func (d *DriveDB) FilesMissingFolders(root string) (int, error) {
+	var count int
+	err := d.Get(&count, treeQuery+" SELECT COUNT(*) FROM tree LEFT JOIN gd ON gd.fid = tree.id WHERE gd.fid IS NULL", root, folderType)
+	return count, err
+}
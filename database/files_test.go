@@ -0,0 +1,149 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/suite"
+)
+
+type FilesSuite struct {
+	suite.Suite
+	db *DriveDB
+}
+
+func (suite *FilesSuite) SetupTest() {
+	suite.db = ConnectDB("sqlite3", fmt.Sprintf("file:%s?mode=memory&cache=shared", suite.T().Name()))
+}
+
+func (suite *FilesSuite) TearDownTest() {
+	suite.NoError(suite.db.Close())
+}
+
+func (suite *FilesSuite) TestFilesGetTree() {
+	suite.NoError(suite.db.FilesReplace("root", []FileDB{
+		{ID: "sub", Parent: "root", Name: "sub", Mime: folderType},
+		{ID: "a", Parent: "root", Name: "a", Size: 10},
+	}))
+	suite.NoError(suite.db.FilesReplace("sub", []FileDB{
+		{ID: "b", Parent: "sub", Name: "b", Size: 20},
+	}))
+	suite.NoError(suite.db.FilesReplace("elsewhere", []FileDB{
+		{ID: "c", Parent: "elsewhere", Name: "c", Size: 30},
+	}))
+
+	files, err := suite.db.FilesGetTree("root")
+	suite.NoError(err)
+	suite.Len(files, 3)
+
+	children, err := suite.db.FilesGetChildren("root")
+	suite.NoError(err)
+	suite.Equal("a", children[0].ID)
+	suite.Equal(int64(10), children[0].ToFile().Size)
+}
+
+func (suite *FilesSuite) TestFilesMissingFolders() {
+	suite.NoError(suite.db.FilesReplace("root", []FileDB{
+		{ID: "sub", Parent: "root", Name: "sub", Mime: folderType},
+		{ID: "other", Parent: "root", Name: "other", Mime: folderType},
+	}))
+	suite.NoError(suite.db.GDInsertItem("root", `["sub","other"]`))
+	suite.NoError(suite.db.GDInsertItem("sub", `[]`))
+
+	missing, err := suite.db.FilesMissingFolders("root")
+	suite.NoError(err)
+	suite.Equal(1, missing)
+}
+
+func (suite *FilesSuite) TestFilesApplyChanges() {
+	suite.NoError(suite.db.FilesReplace("root", []FileDB{
+		{ID: "sub", Parent: "root", Name: "sub", Mime: folderType},
+		{ID: "a", Parent: "root", Name: "a", Size: 10},
+	}))
+	suite.NoError(suite.db.FilesReplace("sub", []FileDB{
+		{ID: "b", Parent: "sub", Name: "b", Size: 20},
+	}))
+	suite.NoError(suite.db.GDInsertItem("root", `["sub"]`))
+	suite.NoError(suite.db.GDInsertItem("sub", `[]`))
+
+	applied, err := suite.db.FilesApplyChanges([]FileChange{
+		{ID: "a", File: FileDB{ID: "a", Parent: "sub", Name: "renamed", Size: 10}},
+		{ID: "b", Removed: true},
+		{ID: "new", File: FileDB{ID: "new", Parent: "root", Name: "new", Mime: folderType}},
+		{ID: "outside", File: FileDB{ID: "outside", Parent: "elsewhere", Name: "outside"}},
+		{ID: "unknown", Removed: true},
+	})
+	suite.NoError(err)
+	suite.Equal(3, applied)
+
+	children, err := suite.db.FilesGetChildren("sub")
+	suite.NoError(err)
+	suite.Len(children, 1)
+	suite.Equal("renamed", children[0].Name)
+
+	_, exists, err := suite.db.FilesGet("outside")
+	suite.NoError(err)
+	suite.False(exists)
+
+	record, _, err := suite.db.GDGet("root")
+	suite.NoError(err)
+	suite.Equal(`["new","sub"]`, record.Subf.String)
+	suite.False(record.Mtime.Valid)
+
+	applied, err = suite.db.FilesApplyChanges([]FileChange{{ID: "sub", Removed: true}})
+	suite.NoError(err)
+	suite.Equal(1, applied)
+
+	_, exists, err = suite.db.GDGet("sub")
+	suite.NoError(err)
+	suite.False(exists)
+	_, exists, err = suite.db.FilesGet("a")
+	suite.NoError(err)
+	suite.False(exists)
+}
+
+func (suite *FilesSuite) TestCachePrune() {
+	suite.NoError(suite.db.FilesReplaceFolders([]CachedFolder{
+		{Fid: "root", Subf: `["sub"]`, Files: []FileDB{{ID: "sub", Parent: "root", Name: "sub", Mime: folderType}}, ListedAt: 100},
+		{Fid: "sub", Subf: `[]`, Files: []FileDB{{ID: "a", Parent: "sub", Name: "a"}}, ListedAt: 300},
+	}))
+	suite.NoError(suite.db.FilesReplace("orphan", []FileDB{{ID: "b", Parent: "orphan", Name: "b"}}))
+
+	oldest, err := suite.db.CacheOldest(1)
+	suite.NoError(err)
+	suite.Equal([]CacheEntry{{Fid: "root", ListedAt: 100, Items: 1}}, oldest)
+
+	removed, err := suite.db.CachePrune(200)
+	suite.NoError(err)
+	suite.Equal(1, removed)
+
+	stats, err := suite.db.CacheStats()
+	suite.NoError(err)
+	suite.Equal(1, stats.Folders)
+	suite.Equal(1, stats.Files)
+	suite.Greater(stats.Size, int64(0))
+}
+
+func (suite *FilesSuite) TestCacheRemove() {
+	suite.NoError(suite.db.FilesReplaceFolders([]CachedFolder{
+		{Fid: "root", Subf: `["sub"]`, Files: []FileDB{{ID: "sub", Parent: "root", Name: "sub", Mime: folderType}}},
+		{Fid: "sub", Subf: `["missing"]`, Files: []FileDB{{ID: "a", Parent: "sub", Name: "a"}}},
+		{Fid: "other", Subf: `[]`},
+	}))
+
+	removed, err := suite.db.CacheRemove("root")
+	suite.NoError(err)
+	suite.Equal(2, removed)
+
+	folders, err := suite.db.CacheTree("other")
+	suite.NoError(err)
+	suite.Len(folders, 1)
+	files, err := suite.db.FilesGetTree("root")
+	suite.NoError(err)
+	suite.Empty(files)
+}
+
+func TestFilesSuite(t *testing.T) {
+	suite.Run(t, new(FilesSuite))
+}
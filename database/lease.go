@@ -0,0 +1,76 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// LeaseAcquire takes the lease of the source and target pair for the owner. The lease of another owner is
+// taken over only if takeover is set and its heartbeat is older than staleBefore. The current holder of the
+// lease is returned along with whether it is the owner.
+func (d *DriveDB) LeaseAcquire(owner LeaseDB, staleBefore int64, takeover bool) (LeaseDB, bool, error) {
+	var holder LeaseDB
+	var acquired bool
+
+	err := d.Transaction(func(tx *sqlx.Tx) error {
+		now := time.Now().Unix()
+		err := tx.Get(&holder, "SELECT * FROM lease WHERE source=? AND target=?", owner.Source, owner.Target)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			res, err := tx.Exec("INSERT OR IGNORE INTO lease (source, target, pid, hostname, acquired, heartbeat) VALUES (?, ?, ?, ?, ?, ?)",
+				owner.Source, owner.Target, owner.Pid, owner.Hostname, now, now)
+			if err != nil {
+				return err
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				// taken by another process in the meantime
+				return tx.Get(&holder, "SELECT * FROM lease WHERE source=? AND target=?", owner.Source, owner.Target)
+			}
+			holder = owner
+			holder.Acquired, holder.Heartbeat = now, now
+			acquired = true
+			return nil
+		case err != nil:
+			return err
+		case holder.Pid == owner.Pid && holder.Hostname == owner.Hostname:
+		case takeover && holder.Heartbeat < staleBefore:
+		default:
+			return nil
+		}
+
+		_, err = tx.Exec("UPDATE lease SET pid=?, hostname=?, acquired=?, heartbeat=? WHERE source=? AND target=?",
+			owner.Pid, owner.Hostname, now, now, owner.Source, owner.Target)
+		if err != nil {
+			return err
+		}
+		holder = owner
+		holder.Acquired, holder.Heartbeat = now, now
+		acquired = true
+		return nil
+	})
+	return holder, acquired, err
+}
+
+// LeaseHeartbeat refreshes the lease and reports whether the owner still holds it.
+func (d *DriveDB) LeaseHeartbeat(owner LeaseDB) (bool, error) {
+	res, err := d.Exec("UPDATE lease SET heartbeat=? WHERE source=? AND target=? AND pid=? AND hostname=?",
+		time.Now().Unix(), owner.Source, owner.Target, owner.Pid, owner.Hostname)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (d *DriveDB) LeaseRelease(owner LeaseDB) error {
+	_, err := d.Exec("DELETE FROM lease WHERE source=? AND target=? AND pid=? AND hostname=?",
+		owner.Source, owner.Target, owner.Pid, owner.Hostname)
+	return err
+}
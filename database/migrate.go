@@ -0,0 +1,152 @@
+package database
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"google.golang.org/api/drive/v3"
+
+	"github.com/xybydy/gdutils/logger"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+type migration struct {
+	version int
+	name    string
+	up      func(*sqlx.Tx) error
+}
+
+// migrations are applied in order on connect, the applied ones are recorded in the schema_version table.
+// Released migrations must never be changed, add a new one instead.
+var migrations = []migration{
+	{1, "initial schema", execFile("migrations/0001_initial.sql")},
+	{2, "files table", execFile("migrations/0002_files.sql")},
+	{3, "move gd.info into files", migrateGdInfo},
+	{4, "hash status", addColumn("hash", "status", `TEXT NOT NULL DEFAULT 'normal'`)},
+	{5, "failed table", execFile("migrations/0005_failed.sql")},
+	{6, "lease table", execFile("migrations/0006_lease.sql")},
+	{7, "changes table", execFile("migrations/0007_changes.sql")},
+	{8, "sa usage table", execFile("migrations/0008_sa_usage.sql")},
+	{9, "task min size", addColumn("task", "minsize", `INTEGER NOT NULL DEFAULT 0`)},
+	{10, "task match mode", addColumn("task", "matchmode", `TEXT NOT NULL DEFAULT ''`)},
+}
+
+const schemaVersionTable = `CREATE TABLE IF NOT EXISTS "schema_version" (
+	"version" INTEGER NOT NULL PRIMARY KEY,
+	"name"  TEXT,
+	"applied_at" INTEGER
+)`
+
+func execFile(name string) func(*sqlx.Tx) error {
+	return func(tx *sqlx.Tx) error {
+		content, err := migrationFiles.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(string(content))
+		return err
+	}
+}
+
+// addColumn adds the column unless it exists, the databases created by other tools may already have it.
+func addColumn(table, column, definition string) func(*sqlx.Tx) error {
+	return func(tx *sqlx.Tx) error {
+		var count int
+		err := tx.Get(&count, "SELECT COUNT(*) FROM pragma_table_info(?) WHERE name=?", table, column)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		_, err = tx.Exec(fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN "%s" %s`, table, column, definition))
+		return err
+	}
+}
+
+// SchemaVersion returns the version of the last applied migration.
+func (d *DriveDB) SchemaVersion() (int, error) {
+	var version int
+	if _, err := d.Exec(schemaVersionTable); err != nil {
+		return 0, err
+	}
+	err := d.Get(&version, "SELECT COALESCE(MAX(version), 0) FROM schema_version")
+	return version, err
+}
+
+// Migrate applies the pending migrations, each in its own transaction.
+func (d *DriveDB) Migrate() error {
+	current, err := d.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		logger.Info("Applying db migration %d: %s", m.version, m.name)
+		err := d.Transaction(func(tx *sqlx.Tx) error {
+			if err := m.up(tx); err != nil {
+				return err
+			}
+			_, err := tx.Exec("INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)", m.version, m.name, time.Now().Unix())
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("db migration %d (%s) failed: %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+// migrateGdInfo moves the items stored as JSON in the info column of the gd table into the files table.
+func migrateGdInfo(tx *sqlx.Tx) error {
+	var rows []GdDB
+	if err := tx.Select(&rows, "SELECT * FROM gd WHERE info IS NOT NULL"); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		var info []*drive.File
+		if row.Info.String != "" {
+			if err := json.Unmarshal([]byte(row.Info.String), &info); err != nil {
+				// The folder is listed again next time instead of being cached as empty.
+				logger.Error("Dropping the unreadable cache of %s: %s", row.Fid.String, err)
+				if _, err := tx.Exec("DELETE FROM gd WHERE id=?", row.ID); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		cachedAt := row.Mtime.Int64
+		if !row.Mtime.Valid {
+			cachedAt = row.Ctime.Int64
+		}
+		if cachedAt == 0 {
+			cachedAt = time.Now().Unix()
+		}
+
+		files := make([]FileDB, 0, len(info))
+		for _, i := range info {
+			files = append(files, NewFileDB(row.Fid.String, i, cachedAt))
+		}
+		if err := replaceFiles(tx, row.Fid.String, files); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE gd SET info=NULL WHERE id=?", row.ID); err != nil {
+			return err
+		}
+	}
+
+	if len(rows) > 0 {
+		logger.Info("%d cached folders migrated to the files table", len(rows))
+	}
+	return nil
+}
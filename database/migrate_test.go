@@ -0,0 +1,64 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/suite"
+)
+
+type MigrateSuite struct {
+	suite.Suite
+	db *DriveDB
+}
+
+// SetupTest creates a db the way it used to be created by hand, before the migrations existed.
+func (suite *MigrateSuite) SetupTest() {
+	suite.db = &DriveDB{db: sqlx.MustConnect("sqlite3", fmt.Sprintf("file:%s?mode=memory&cache=shared", suite.T().Name()))}
+	suite.Require().NoError(suite.db.Transaction(execFile("migrations/0001_initial.sql")))
+}
+
+func (suite *MigrateSuite) TearDownTest() {
+	suite.NoError(suite.db.Close())
+}
+
+func (suite *MigrateSuite) TestMigrate() {
+	_, err := suite.db.Exec(`INSERT INTO gd (fid, info, subf) VALUES
+		('root', '[{"id":"sub","name":"sub","mimeType":"application/vnd.google-apps.folder"},{"id":"a","name":"a","size":"10","md5Checksum":"x"}]', '["sub"]'),
+		('sub', '[{"id":"b","name":"b","size":"20"}]', '[]'),
+		('broken', '{', '[]')`)
+	suite.Require().NoError(err)
+	suite.Require().NoError(suite.db.HashAdd("gid", "md5"))
+
+	suite.Require().NoError(suite.db.Migrate())
+
+	version, err := suite.db.SchemaVersion()
+	suite.NoError(err)
+	suite.Equal(len(migrations), version)
+
+	files, err := suite.db.FilesGetTree("root")
+	suite.NoError(err)
+	suite.Len(files, 3)
+
+	_, exists, err := suite.db.GDGet("broken")
+	suite.NoError(err)
+	suite.False(exists)
+
+	ids, err := suite.db.HashGetIDs("md5")
+	suite.NoError(err)
+	suite.Equal([]string{"gid"}, ids)
+
+	suite.NoError(suite.db.Migrate())
+}
+
+func (suite *MigrateSuite) TestAddColumnExisting() {
+	_, err := suite.db.Exec(`ALTER TABLE hash ADD COLUMN status TEXT NOT NULL DEFAULT 'normal'`)
+	suite.Require().NoError(err)
+
+	suite.NoError(suite.db.Migrate())
+}
+
+func TestMigrateSuite(t *testing.T) {
+	suite.Run(t, new(MigrateSuite))
+}
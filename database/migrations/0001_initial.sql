@@ -1,4 +1,4 @@
-CREATE TABLE "gd" (
+CREATE TABLE IF NOT EXISTS "gd" (
                       "id"  INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT UNIQUE,
                       "fid" TEXT NOT NULL UNIQUE,
                       "info"  TEXT,
@@ -8,11 +8,11 @@ CREATE TABLE "gd" (
                       "mtime" INTEGER
 );
 
-CREATE UNIQUE INDEX "gd_fid" ON "gd" (
+CREATE UNIQUE INDEX IF NOT EXISTS "gd_fid" ON "gd" (
   "fid"
 );
 
-CREATE TABLE "task" (
+CREATE TABLE IF NOT EXISTS "task" (
                         "id"  INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT UNIQUE,
                         "source"  TEXT NOT NULL,
                         "target"  TEXT NOT NULL,
@@ -23,36 +23,36 @@ CREATE TABLE "task" (
                         "ftime" INTEGER
 );
 
-CREATE UNIQUE INDEX "task_source_target" ON "task" (
+CREATE UNIQUE INDEX IF NOT EXISTS "task_source_target" ON "task" (
   "source",
   "target"
 );
 
-CREATE TABLE "copied" (
+CREATE TABLE IF NOT EXISTS "copied" (
                           "taskid"  INTEGER,
                           "fileid"  TEXT
 );
 
-CREATE INDEX "copied_taskid" ON "copied" ("taskid");
+CREATE INDEX IF NOT EXISTS "copied_taskid" ON "copied" ("taskid");
 
-CREATE TABLE "bookmark" (
+CREATE TABLE IF NOT EXISTS "bookmark" (
                             "alias"  TEXT,
                             "target"  TEXT
 );
 
-CREATE UNIQUE INDEX "bookmark_alias" ON "bookmark" (
+CREATE UNIQUE INDEX IF NOT EXISTS "bookmark_alias" ON "bookmark" (
   "alias"
 );
 
-CREATE TABLE "hash" (
+CREATE TABLE IF NOT EXISTS "hash" (
                         "md5" TEXT NOT NULL,
                         "gid" TEXT NOT NULL UNIQUE
 );
 
-CREATE INDEX "hash_md5" ON "hash" (
+CREATE INDEX IF NOT EXISTS "hash_md5" ON "hash" (
   "md5"
 );
 
-CREATE UNIQUE INDEX "hash_gid" ON "hash" (
+CREATE UNIQUE INDEX IF NOT EXISTS "hash_gid" ON "hash" (
   "gid"
-);
\ No newline at end of file
+);
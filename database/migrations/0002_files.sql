@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS "files" (
+                         "id"  TEXT NOT NULL PRIMARY KEY,
+                         "parent"  TEXT NOT NULL,
+                         "name"  TEXT,
+                         "md5" TEXT,
+                         "size"  INTEGER DEFAULT 0,
+                         "mime"  TEXT,
+                         "mtime" TEXT,
+                         "cached_at" INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS "files_parent" ON "files" ("parent");
+
+CREATE INDEX IF NOT EXISTS "files_md5" ON "files" ("md5");
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS "failed" (
+                          "taskid"  INTEGER NOT NULL,
+                          "fileid"  TEXT NOT NULL,
+                          "class" INTEGER,
+                          "message" TEXT,
+                          "attempts" INTEGER NOT NULL DEFAULT 1,
+                          "mtime" INTEGER,
+                          PRIMARY KEY ("taskid", "fileid")
+);
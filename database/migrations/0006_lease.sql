@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS "lease" (
+                         "source"  TEXT NOT NULL,
+                         "target"  TEXT NOT NULL,
+                         "pid"  INTEGER NOT NULL,
+                         "hostname"  TEXT NOT NULL,
+                         "acquired"  INTEGER,
+                         "heartbeat"  INTEGER,
+                         PRIMARY KEY ("source", "target")
+);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS "changes" (
+                           "fid"  TEXT NOT NULL PRIMARY KEY,
+                           "driveid"  TEXT NOT NULL,
+                           "token"  TEXT NOT NULL,
+                           "mtime"  INTEGER
+);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS "sa_usage" (
+                            "email"  TEXT NOT NULL,
+                            "day"  TEXT NOT NULL,
+                            "bytes"  INTEGER NOT NULL DEFAULT 0,
+                            PRIMARY KEY ("email", "day")
+);
@@ -3,6 +3,7 @@ package database
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
 
 	"google.golang.org/api/drive/v3"
 
@@ -27,7 +28,7 @@ func (g GdDB) GetInfo() []*drive.File {
 }
 
 func (g GdDB) ContainsSummary() bool {
-	return g.Summary.Valid
+	return g.Summary.Valid && g.Summary.String != ""
 }
 
 func (g GdDB) GetSummary() GdDBSummary {
@@ -63,6 +64,85 @@ type SummaryItemInfo struct {
 
 type GdSubf []string
 
+// FileDB is a single Drive item cached under the folder it was listed in.
+type FileDB struct {
+	ID       string
+	Parent   string
+	Name     string
+	Md5      sql.NullString
+	Size     int64
+	Mime     string
+	Mtime    sql.NullString
+	CachedAt int64 `db:"cached_at"`
+}
+
+func NewFileDB(parent string, f *drive.File, cachedAt int64) FileDB {
+	return FileDB{
+		ID:       f.Id,
+		Parent:   parent,
+		Name:     f.Name,
+		Md5:      sql.NullString{String: f.Md5Checksum, Valid: f.Md5Checksum != ""},
+		Size:     f.Size,
+		Mime:     f.MimeType,
+		Mtime:    sql.NullString{String: f.ModifiedTime, Valid: f.ModifiedTime != ""},
+		CachedAt: cachedAt,
+	}
+}
+
+func (f FileDB) ToFile() *drive.File {
+	return &drive.File{
+		Id:           f.ID,
+		Name:         f.Name,
+		Md5Checksum:  f.Md5.String,
+		Size:         f.Size,
+		MimeType:     f.Mime,
+		ModifiedTime: f.Mtime.String,
+		Parents:      []string{f.Parent},
+	}
+}
+
+// CachedFolder is the listing of a folder, Subf is the JSON array of the ids of its sub folders. ListedAt is
+// when the folder is listed, it is the time of caching if it is zero.
+type CachedFolder struct {
+	Fid      string
+	Subf     string
+	Files    []FileDB
+	ListedAt int64
+}
+
+// CacheStats is the number of rows in the tables of the db, and the size of the db file.
+type CacheStats struct {
+	Folders int
+	Files   int
+	Hashes  int
+	Tasks   int
+	Tracked int
+	Size    int64
+}
+
+// CacheEntry is a cached folder along with the number of its cached items.
+type CacheEntry struct {
+	Fid      string
+	ListedAt int64 `db:"listed_at"`
+	Items    int
+}
+
+// ChangesDB is the start of the changes of the shared drive which are not applied to the cached tree of Fid yet.
+type ChangesDB struct {
+	Fid     string
+	DriveID string
+	Token   string
+	Mtime   sql.NullInt64
+}
+
+// FileChange is a change of a file in the drive. The file is removed from the cache if it is deleted,
+// or if its new parent is not cached.
+type FileChange struct {
+	ID      string
+	Removed bool
+	File    FileDB
+}
+
 type TaskDB struct {
 	ID      int
 	Source  string
@@ -72,6 +152,12 @@ type TaskDB struct {
 	Mapping string
 	Ctime   sql.NullInt64
 	Ftime   sql.NullInt64
+	// MinSize and MatchMode are the options the task is started with, MatchMode is empty unless it is incremental
+	MinSize   int    `db:"minsize"`
+	MatchMode string `db:"matchmode"`
+
+	CopiedCount int `db:"copied_count"`
+	FailedCount int `db:"failed_count"`
 }
 
 type CopiedDB struct {
@@ -79,11 +165,38 @@ type CopiedDB struct {
 	FileID string
 }
 
+// FailedDB is a file which could not be copied by a task. Class is one of the utils.RequestErrorType values.
+type FailedDB struct {
+	TaskID   int
+	FileID   string
+	Class    int
+	Message  string
+	Attempts int
+	Mtime    sql.NullInt64
+}
+
 type BookmarkDB struct {
 	Alias  string
 	Target string
 }
 
+// LeaseDB is the lock of a process on a source and target pair. Heartbeat is refreshed by the owner
+// while it is running, a lease with an old heartbeat belongs to a dead process.
+type LeaseDB struct {
+	Source    string
+	Target    string
+	Pid       int
+	Hostname  string
+	Acquired  int64
+	Heartbeat int64
+}
+
+func (l LeaseDB) Owner() string {
+	return fmt.Sprintf("pid %d on %s", l.Pid, l.Hostname)
+}
+
+const HashStatusNormal = "normal"
+
 type HashDB struct {
 	Md5    string
 	GID    string
@@ -97,3 +210,10 @@ type ItemInfo struct {
 	Name         string
 	Parents      []string
 }
+
+// SaUsageDB is the number of bytes copied by the Sa on the UTC day, Day is formatted as 2006-01-02.
+type SaUsageDB struct {
+	Email string
+	Day   string
+	Bytes int64
+}
@@ -5,11 +5,14 @@ import (
 	"errors"
 	"time"
 
+	"github.com/jmoiron/sqlx"
+
 	"github.com/xybydy/gdutils/logger"
 )
 
 func (d *DriveDB) HashExist(id string) (bool, error) {
-	_, err := d.Exec("SELECT * FROM hash WHERE gid = ?", id)
+	var gid string
+	err := d.Get(&gid, "SELECT gid FROM hash WHERE gid = ?", id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return false, nil
@@ -24,14 +27,15 @@ func (d *DriveDB) HashAdd(id, md5 string) error {
 	return err
 }
 
-func (d *DriveDB) HashGetID(md5 string) (string, error) {
-	var hash HashDB
-	err := d.Get(&hash, "select * from hash where md5=? and status=? LIMIT=1", md5)
-	return hash.Md5, err
+func (d *DriveDB) HashGetIDs(md5 string) ([]string, error) {
+	var ids []string
+	err := d.Select(&ids, "SELECT gid FROM hash WHERE md5=? AND status=?", md5, HashStatusNormal)
+	return ids, err
 }
 
 func (d *DriveDB) GDExist(fid string) (bool, error) {
-	_, err := d.Exec("SELECT fid FROM gd WHERE fid = ?", fid)
+	var id string
+	err := d.Get(&id, "SELECT fid FROM gd WHERE fid = ?", fid)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return false, nil
@@ -53,27 +57,54 @@ func (d *DriveDB) GDGet(fid string) (GdDB, bool, error) {
 	return record, true, nil
 }
 
-func (d *DriveDB) GDUpdateItem(fid, info, subf string) error {
+func (d *DriveDB) GDUpdateItem(fid, subf string) error {
 	logger.Debug("updating db for - %s", fid)
-	_, err := d.Exec("UPDATE gd SET info=?, subf=?, mtime=? WHERE fid=?", info, subf, time.Now().Unix(), fid)
+	_, err := d.Exec("UPDATE gd SET info=NULL, subf=?, mtime=? WHERE fid=?", subf, time.Now().Unix(), fid)
 	return err
 }
 
-func (d *DriveDB) GDInsertItem(fid, info, subf string) error {
+func (d *DriveDB) GDInsertItem(fid, subf string) error {
 	logger.Debug("inserting db for - %s", fid)
-	_, err := d.Exec("INSERT INTO gd (fid, info, subf, ctime) VALUES (?, ?, ?, ?)", fid, info, subf, time.Now().Unix())
+	_, err := d.Exec("INSERT INTO gd (fid, subf, ctime) VALUES (?, ?, ?)", fid, subf, time.Now().Unix())
+	return err
+}
+
+// GDClearDriveSummaries removes the summaries of the cached trees which are kept up to date with the
+// changes of the shared drive.
+func (d *DriveDB) GDClearDriveSummaries(driveID string) error {
+	_, err := d.Exec("UPDATE gd SET summary=NULL WHERE fid IN (SELECT fid FROM changes WHERE driveid=?)", driveID)
 	return err
 }
 
+// GDUpdateSummary sets the summary of the folder. The mtime is left as is, it is the time the folder is listed.
 func (d *DriveDB) GDUpdateSummary(fid, sum string) error {
 	logger.Debug("updating summary for - %s", fid)
-	_, err := d.Exec("UPDATE gd SET summary=?, mtime=? WHERE fid=?", sum, time.Now().Unix(), fid)
+	_, err := d.Exec("UPDATE gd SET summary=? WHERE fid=?", sum, fid)
 	return err
 }
 
 func (d *DriveDB) TaskGet(source, target string) (TaskDB, bool, error) {
 	record := TaskDB{}
-	err := d.Get(&record, "select id, status from task where source=? and target=?", source, target)
+	err := d.Get(&record, "select * from task where source=? and target=?", source, target)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return record, false, nil
+		}
+		return record, false, err
+	}
+	return record, true, nil
+}
+
+// TaskList returns all the tasks along with the number of files copied by each of them.
+func (d *DriveDB) TaskList() ([]TaskDB, error) {
+	var tasks []TaskDB
+	err := d.Select(&tasks, "select task.*, (select count(*) from copied where copied.taskid=task.id) as copied_count, (select count(*) from failed where failed.taskid=task.id) as failed_count from task order by id")
+	return tasks, err
+}
+
+func (d *DriveDB) TaskGetByID(id int) (TaskDB, bool, error) {
+	record := TaskDB{}
+	err := d.Get(&record, "select task.*, (select count(*) from copied where copied.taskid=task.id) as copied_count, (select count(*) from failed where failed.taskid=task.id) as failed_count from task where id=?", id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return record, false, nil
@@ -83,13 +114,27 @@ func (d *DriveDB) TaskGet(source, target string) (TaskDB, bool, error) {
 	return record, true, nil
 }
 
+// TaskDeleteByID removes the task together with its copied and failed records.
+func (d *DriveDB) TaskDeleteByID(id int) error {
+	return d.Transaction(func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec("delete from copied where taskid=?", id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("delete from failed where taskid=?", id); err != nil {
+			return err
+		}
+		_, err := tx.Exec("delete from task where id=?", id)
+		return err
+	})
+}
+
 func (d *DriveDB) TaskStatusUpdate(id int, status string) error {
 	_, err := d.Exec("update task set status=?, ftime=? where id=?", status, time.Now().Unix(), id)
 	return err
 }
 
-func (d *DriveDB) TaskUpdate(id int, status, rootMapping string) error {
-	_, err := d.Exec("update task set status=?, mapping=? where id=?", status, rootMapping, id)
+func (d *DriveDB) TaskUpdate(id int, status, rootMapping string, minSize int, matchMode string) error {
+	_, err := d.Exec("update task set status=?, mapping=?, minsize=?, matchmode=? where id=?", status, rootMapping, minSize, matchMode, id)
 	return err
 }
 
@@ -98,8 +143,9 @@ func (d *DriveDB) TaskAddMapping(taskID int, mappingRecord string) error {
 	return err
 }
 
-func (d *DriveDB) TaskInsert(source, target, status, rootMapping string) (sql.Result, error) {
-	res, err := d.Exec("insert into task (source, target, status, mapping, ctime) values (?, ?, ?, ?, ?)", source, target, status, rootMapping, time.Now().Unix())
+func (d *DriveDB) TaskInsert(source, target, status, rootMapping string, minSize int, matchMode string) (sql.Result, error) {
+	res, err := d.Exec("insert into task (source, target, status, mapping, minsize, matchmode, ctime) values (?, ?, ?, ?, ?, ?, ?)",
+		source, target, status, rootMapping, minSize, matchMode, time.Now().Unix())
 	return res, err
 }
 
@@ -125,3 +171,27 @@ func (d *DriveDB) CopiedDelete(id int) error {
 	_, err := d.Exec("delete from copied where taskid=?", id)
 	return err
 }
+
+// FailedInsert records the failure of the file, the attempt count is increased if it has failed before.
+func (d *DriveDB) FailedInsert(taskID int, fileID string, class int, message string) error {
+	_, err := d.Exec(`INSERT INTO failed (taskid, fileid, class, message, attempts, mtime) VALUES (?, ?, ?, ?, 1, ?)
+		ON CONFLICT (taskid, fileid) DO UPDATE SET class=excluded.class, message=excluded.message, attempts=attempts+1, mtime=excluded.mtime`,
+		taskID, fileID, class, message, time.Now().Unix())
+	return err
+}
+
+func (d *DriveDB) FailedGet(taskID int) ([]FailedDB, error) {
+	var failed []FailedDB
+	err := d.Select(&failed, "select * from failed where taskid=? order by fileid", taskID)
+	return failed, err
+}
+
+func (d *DriveDB) FailedDeleteFile(taskID int, fileID string) error {
+	_, err := d.Exec("delete from failed where taskid=? and fileid=?", taskID, fileID)
+	return err
+}
+
+func (d *DriveDB) FailedDelete(taskID int) error {
+	_, err := d.Exec("delete from failed where taskid=?", taskID)
+	return err
+}
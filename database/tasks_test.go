@@ -0,0 +1,151 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/suite"
+)
+
+type TasksSuite struct {
+	suite.Suite
+	db *DriveDB
+}
+
+func (suite *TasksSuite) SetupTest() {
+	suite.db = ConnectDB("sqlite3", fmt.Sprintf("file:%s?mode=memory&cache=shared", suite.T().Name()))
+}
+
+func (suite *TasksSuite) TearDownTest() {
+	suite.NoError(suite.db.Close())
+}
+
+func (suite *TasksSuite) TestTaskList() {
+	_, err := suite.db.TaskInsert("src1", "dst", "copying", "src1 root1\n", 0, "")
+	suite.NoError(err)
+	_, err = suite.db.TaskInsert("src2", "dst", "finished", "src2 root2\n", 1024, "md5")
+	suite.NoError(err)
+	suite.NoError(suite.db.CopiedInsert(2, "a"))
+	suite.NoError(suite.db.CopiedInsert(2, "b"))
+
+	tasks, err := suite.db.TaskList()
+	suite.NoError(err)
+	suite.Len(tasks, 2)
+	suite.Equal(0, tasks[0].CopiedCount)
+	suite.Equal(2, tasks[1].CopiedCount)
+	suite.Equal("src2 root2\n", tasks[1].Mapping)
+	suite.Equal(1024, tasks[1].MinSize)
+	suite.Equal("md5", tasks[1].MatchMode)
+
+	suite.NoError(suite.db.TaskUpdate(2, "copying", "src2 root3\n", 0, ""))
+	task, _, err := suite.db.TaskGetByID(2)
+	suite.NoError(err)
+	suite.Equal(0, task.MinSize)
+	suite.Equal("", task.MatchMode)
+}
+
+func (suite *TasksSuite) TestTaskDeleteByID() {
+	_, err := suite.db.TaskInsert("src", "dst", "finished", "src root\n", 0, "")
+	suite.NoError(err)
+	suite.NoError(suite.db.CopiedInsert(1, "a"))
+
+	suite.NoError(suite.db.TaskDeleteByID(1))
+
+	_, exists, err := suite.db.TaskGetByID(1)
+	suite.NoError(err)
+	suite.False(exists)
+	copied, err := suite.db.CopiedGet(1)
+	suite.NoError(err)
+	suite.Empty(copied)
+}
+
+func (suite *TasksSuite) TestFailedInsert() {
+	_, err := suite.db.TaskInsert("src", "dst", "finished", "src root\n", 0, "")
+	suite.NoError(err)
+	suite.NoError(suite.db.FailedInsert(1, "a", 0, "backend error"))
+	suite.NoError(suite.db.FailedInsert(1, "a", 2, "not found"))
+	suite.NoError(suite.db.FailedInsert(1, "b", 0, "backend error"))
+
+	failed, err := suite.db.FailedGet(1)
+	suite.NoError(err)
+	suite.Len(failed, 2)
+	suite.Equal(2, failed[0].Attempts)
+	suite.Equal(2, failed[0].Class)
+	suite.Equal("not found", failed[0].Message)
+
+	suite.NoError(suite.db.FailedDeleteFile(1, "a"))
+	task, _, err := suite.db.TaskGetByID(1)
+	suite.NoError(err)
+	suite.Equal(1, task.FailedCount)
+
+	suite.NoError(suite.db.TaskDeleteByID(1))
+	failed, err = suite.db.FailedGet(1)
+	suite.NoError(err)
+	suite.Empty(failed)
+}
+
+func (suite *TasksSuite) TestLeaseAcquire() {
+	first := LeaseDB{Source: "src", Target: "dst", Pid: 1, Hostname: "a"}
+	second := LeaseDB{Source: "src", Target: "dst", Pid: 2, Hostname: "b"}
+	now := time.Now().Unix()
+
+	_, acquired, err := suite.db.LeaseAcquire(first, now-60, false)
+	suite.NoError(err)
+	suite.True(acquired)
+
+	holder, acquired, err := suite.db.LeaseAcquire(second, now-60, true)
+	suite.NoError(err)
+	suite.False(acquired, "a lease with a recent heartbeat is never taken over")
+	suite.Equal(1, holder.Pid)
+
+	_, acquired, err = suite.db.LeaseAcquire(second, now+60, false)
+	suite.NoError(err)
+	suite.False(acquired)
+
+	_, acquired, err = suite.db.LeaseAcquire(second, now+60, true)
+	suite.NoError(err)
+	suite.True(acquired)
+
+	held, err := suite.db.LeaseHeartbeat(first)
+	suite.NoError(err)
+	suite.False(held)
+
+	suite.NoError(suite.db.LeaseRelease(second))
+	_, acquired, err = suite.db.LeaseAcquire(first, now-60, false)
+	suite.NoError(err)
+	suite.True(acquired)
+}
+
+func (suite *TasksSuite) TestBookmark() {
+	suite.NoError(suite.db.BookmarkInsert("movies", "folder"))
+	suite.Error(suite.db.BookmarkInsert("movies", "other"))
+
+	bookmark, exists, err := suite.db.BookmarkGet("movies")
+	suite.NoError(err)
+	suite.True(exists)
+	suite.Equal("folder", bookmark.Target)
+
+	removed, err := suite.db.BookmarkDelete("movies")
+	suite.NoError(err)
+	suite.True(removed)
+	bookmarks, err := suite.db.BookmarkList()
+	suite.NoError(err)
+	suite.Empty(bookmarks)
+}
+
+func (suite *TasksSuite) TestSaUsageAdd() {
+	suite.NoError(suite.db.SaUsageAdd("a@sa", "2026-01-01", 10))
+	suite.NoError(suite.db.SaUsageAdd("a@sa", "2026-01-01", 5))
+	suite.NoError(suite.db.SaUsageAdd("a@sa", "2026-01-02", 7))
+	suite.NoError(suite.db.SaUsageAdd("b@sa", "2026-01-01", 1))
+
+	usage, err := suite.db.SaUsageGet("2026-01-01")
+	suite.NoError(err)
+	suite.Equal([]SaUsageDB{{"a@sa", "2026-01-01", 15}, {"b@sa", "2026-01-01", 1}}, usage)
+}
+
+func TestTasksSuite(t *testing.T) {
+	suite.Run(t, new(TasksSuite))
+}
@@ -0,0 +1,14 @@
+package database
+
+// SaUsageAdd adds the bytes to the bytes copied by the Sa on the day.
+func (d *DriveDB) SaUsageAdd(email, day string, bytes int64) error {
+	_, err := d.Exec(`INSERT INTO sa_usage (email, day, bytes) VALUES (?, ?, ?)
+		ON CONFLICT (email, day) DO UPDATE SET bytes=bytes+excluded.bytes`, email, day, bytes)
+	return err
+}
+
+func (d *DriveDB) SaUsageGet(day string) ([]SaUsageDB, error) {
+	var usage []SaUsageDB
+	err := d.Select(&usage, "SELECT * FROM sa_usage WHERE day=? ORDER BY email", day)
+	return usage, err
+}
@@ -0,0 +1,75 @@
+package gd
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/xybydy/gdutils/summary"
+)
+
+var ErrBookmarkNotFound = errors.New("bookmark not found")
+
+var aliasPattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// BookmarkAdd saves the folder id under the alias, so that the alias can be used in place of the id.
+func BookmarkAdd(alias, fid string) error {
+	if !aliasPattern.MatchString(alias) {
+		return fmt.Errorf("invalid bookmark name %q, use letters, digits, dots, dashes and underscores", alias)
+	}
+	if alias == "root" || alias == "appDataFolder" || alias == "photos" {
+		return fmt.Errorf("%q cannot be used as a bookmark name", alias)
+	}
+	if !validateFid(fid) {
+		return errors.New("invalid folder id")
+	}
+
+	_, exists, err := db.BookmarkGet(alias)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("bookmark %s already exists, remove it first", alias)
+	}
+	if err := db.BookmarkInsert(alias, fid); err != nil {
+		return err
+	}
+	fmt.Printf("Bookmark %s -> %s is added\n", alias, fid)
+	return nil
+}
+
+// BookmarkList prints the bookmarks.
+func BookmarkList() error {
+	bookmarks, err := db.BookmarkList()
+	if err != nil {
+		return err
+	}
+	if len(bookmarks) == 0 {
+		fmt.Println("No bookmarks found")
+		return nil
+	}
+	fmt.Print(summary.MakeBookmarkTable(bookmarks))
+	return nil
+}
+
+// BookmarkRemove deletes the bookmark, the folder is left untouched.
+func BookmarkRemove(alias string) error {
+	removed, err := db.BookmarkDelete(alias)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return fmt.Errorf("%w: %s", ErrBookmarkNotFound, alias)
+	}
+	fmt.Printf("Bookmark %s is removed\n", alias)
+	return nil
+}
+
+// ResolveID returns the folder id the bookmark points to, or the argument itself if it is not a bookmark.
+func ResolveID(arg string) (string, error) {
+	bookmark, exists, err := db.BookmarkGet(arg)
+	if err != nil || !exists {
+		return arg, err
+	}
+	return bookmark.Target, nil
+}
@@ -0,0 +1,209 @@
+package gd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+
+	"github.com/xybydy/gdutils/database"
+	"github.com/xybydy/gdutils/logger"
+	"github.com/xybydy/gdutils/summary"
+)
+
+// cacheExportVersion is the version of the export format, the files of the other versions are not imported.
+const cacheExportVersion = 1
+
+// cacheExport is a cached tree written by cache export, so that a walk can be shared with other machines.
+type cacheExport struct {
+	Version  int              `json:"version"`
+	Root     string           `json:"root"`
+	Exported int64            `json:"exported"`
+	Folders  []exportedFolder `json:"folders"`
+}
+
+type exportedFolder struct {
+	ID       string        `json:"id"`
+	ListedAt int64         `json:"listed_at"`
+	Items    []*drive.File `json:"items"`
+}
+
+// cachePolicy decides whether the cached listing of a folder can be used instead of listing it again.
+type cachePolicy struct {
+	// maxAge is how long a listing is used, zero keeps it until it is updated with -u
+	maxAge time.Duration
+	// checkModified makes the modifiedTime of the folder compared with the time it is listed
+	checkModified bool
+}
+
+var cache cachePolicy
+
+// SetCachePolicy sets how long the cached folders are used, and whether their modifiedTime is checked
+// before their cached items are used.
+func SetCachePolicy(maxAge time.Duration, checkModified bool) {
+	logger.Debugw("Cache policy", "maxAge", maxAge, "checkModified", checkModified)
+	cache = cachePolicy{maxAge: maxAge, checkModified: checkModified}
+}
+
+// listedAt returns when the folder is listed last.
+func listedAt(record database.GdDB) time.Time {
+	if record.Mtime.Valid && record.Mtime.Int64 > 0 {
+		return time.Unix(record.Mtime.Int64, 0)
+	}
+	return time.Unix(record.Ctime.Int64, 0)
+}
+
+// expired reports whether the listing of the folder is older than the max age.
+func (c cachePolicy) expired(record database.GdDB, now time.Time) bool {
+	return c.maxAge > 0 && now.Sub(listedAt(record)) > c.maxAge
+}
+
+// cacheExpired reports whether the folder is cached and its listing is older than the max age.
+func cacheExpired(fid string) bool {
+	record, exists, err := db.GDGet(fid)
+	if err != nil {
+		logger.Error("", err)
+		return false
+	}
+	return exists && cache.expired(record, time.Now())
+}
+
+// fresh reports whether the cached items of the folder can be used. If checkModified is set, the folder is
+// looked up, and it is listed again if it is modified after it is listed. The cache is used if the folder
+// cannot be looked up.
+func (c cachePolicy) fresh(ctx context.Context, record database.GdDB) bool {
+	if c.expired(record, time.Now()) {
+		logger.Debug("Cache of %s is expired", record.Fid.String)
+		return false
+	}
+	if !c.checkModified {
+		return true
+	}
+
+	args := ListArgs{supportsAllDrives: true}
+	args.Fields = []googleapi.Field{"id", "modifiedTime"}
+	f, err := fileGetCall(ctx, record.Fid.String, args)
+	if err != nil {
+		logger.Error("Unable to check if %s is modified: %s", record.Fid.String, err)
+		return true
+	}
+	modified, err := time.Parse(time.RFC3339, f.ModifiedTime)
+	if err != nil {
+		return true
+	}
+	if modified.After(listedAt(record)) {
+		logger.Debug("%s is modified after it is cached", record.Fid.String)
+		return false
+	}
+	return true
+}
+
+// CacheStats prints the row counts and the size of the db, and the folders which are listed the longest time ago.
+func CacheStats() error {
+	stats, err := db.CacheStats()
+	if err != nil {
+		return err
+	}
+	oldest, err := db.CacheOldest(10)
+	if err != nil {
+		return err
+	}
+	fmt.Print(summary.MakeCacheStats(stats, oldest))
+	return nil
+}
+
+// CachePrune removes the cached folders which are listed longer ago than olderThan.
+func CachePrune(olderThan time.Duration) error {
+	if olderThan <= 0 {
+		return fmt.Errorf("invalid age: %s", olderThan)
+	}
+	removed, err := db.CachePrune(time.Now().Add(-olderThan).Unix())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Folders removed from the cache: %d\n", removed)
+	return nil
+}
+
+// CacheRemove removes the cached folder along with all the cached folders under it.
+func CacheRemove(fid string) error {
+	removed, err := db.CacheRemove(fid)
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return fmt.Errorf("%s is not cached", fid)
+	}
+	fmt.Printf("Folders removed from the cache: %d\n", removed)
+	return nil
+}
+
+// CacheExport writes the cached tree of the folder into the file.
+func CacheExport(fid, path string) error {
+	folders, err := db.CacheTree(fid)
+	if err != nil {
+		return err
+	}
+	if len(folders) == 0 {
+		return fmt.Errorf("%s is not cached", fid)
+	}
+
+	export := cacheExport{Version: cacheExportVersion, Root: fid, Exported: time.Now().Unix()}
+	var items int
+	for _, i := range folders {
+		files, err := getCachedFiles(i.Fid.String)
+		if err != nil {
+			return err
+		}
+		export.Folders = append(export.Folders, exportedFolder{ID: i.Fid.String, ListedAt: listedAt(i).Unix(), Items: files})
+		items += len(files)
+	}
+
+	content, err := json.Marshal(export)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, content, 0666); err != nil {
+		return err
+	}
+	fmt.Printf("Folders: %d, Items: %d exported to %s\n", len(export.Folders), items, path)
+	return nil
+}
+
+// CacheImport caches the tree written by cache export. The folders keep the time they are listed at, so the
+// max age applies to them as if they were listed on this machine.
+func CacheImport(path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var export cacheExport
+	if err := json.Unmarshal(content, &export); err != nil {
+		return fmt.Errorf("invalid cache export %s: %w", path, err)
+	}
+	if export.Version != cacheExportVersion {
+		return fmt.Errorf("unsupported cache export version %d in %s", export.Version, path)
+	}
+
+	folders := make([]database.CachedFolder, 0, len(export.Folders))
+	var items int
+	for _, i := range export.Folders {
+		folder := newCachedFolder(i.ID, i.Items, i.ListedAt)
+		folder.ListedAt = i.ListedAt
+		folders = append(folders, folder)
+		items += len(i.Items)
+	}
+	if err := db.FilesReplaceFolders(folders); err != nil {
+		return err
+	}
+	// the summary is made again from the imported tree
+	if err := db.GDUpdateSummary(export.Root, ""); err != nil {
+		logger.Error("", err)
+	}
+	fmt.Printf("Folders: %d, Items: %d imported from %s\n", len(folders), items, path)
+	return nil
+}
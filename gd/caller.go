@@ -2,8 +2,11 @@ package gd
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/jwt"
 	"google.golang.org/api/drive/v3"
 	"google.golang.org/api/option"
 
@@ -13,131 +16,179 @@ import (
 	"github.com/xybydy/gdutils/utils"
 )
 
+// newService returns a drive service made with the next service account, or with the user account if no
+// service accounts are set up. The service account is nil for the user account.
+func newService(ctx context.Context) (*drive.Service, *jwt.Config, error) {
+	if userToken != nil {
+		service, err := drive.NewService(ctx, option.WithHTTPClient(oauth2.NewClient(ctx, userToken)))
+		return service, nil, err
+	}
+
+	saFile, err := SaConfigs.UseSa(ctx)
+	if err != nil {
+		logger.Error("", err)
+		return nil, nil, err
+	}
+	service, err := drive.NewService(ctx, option.WithHTTPClient(auth.NewServiceAccountClient(ctx, saFile)))
+	if err != nil {
+		logger.Error("", err)
+		SaConfigs.MarkFinished(saFile)
+		return nil, nil, err
+	}
+	return service, saFile, nil
+}
+
+// releaseSa gives the service account back once the request is done.
+func releaseSa(saFile *jwt.Config) {
+	if saFile != nil {
+		SaConfigs.MarkFinished(saFile)
+	}
+}
+
+func accountEmail(saFile *jwt.Config) string {
+	if saFile == nil {
+		return ""
+	}
+	return saFile.Email
+}
+
+// rateLimited puts the service account which hit a rate limit to cool down, the user account waits before
+// it is retried.
+func rateLimited(saFile *jwt.Config, retry int, err error) {
+	if saFile != nil {
+		SaConfigs.RateLimited(saFile, utils.RequestErrorReason(err))
+		return
+	}
+	utils.ExponentialBackoffSleep(retry)
+}
+
+// retriesExhausted returns the error of the last attempt once the retries are exhausted, so that the error
+// can still be classified by utils.RequestErrorType.
+func retriesExhausted(call string, err error) error {
+	if err == nil {
+		return errors.Errorf("no chance to %s", call)
+	}
+	return fmt.Errorf("no chance to %s: %w", call, err)
+}
+
 func driveCall(ctx context.Context, fid string) (*drive.Drive, error) {
 	logger.Debug("%s - %s", "Drivecall request call args", fid)
 
+	var lastErr error
 	for retry := 0; retry <= config.RetryLimit; retry++ {
 		select {
 		case <-ctx.Done():
 			logger.Debug("", "Cancelled by user")
 			return nil, errors.Errorf("Cancelled by user")
 		default:
-			saFile, err := SaConfigs.UseSa()
+			service, saFile, err := newService(ctx)
 			if err != nil {
-				logger.Error("", err)
-			}
-			client := auth.NewServiceAccountClient(ctx, saFile)
-
-			service, err := drive.NewService(ctx, option.WithHTTPClient(client))
-			if err != nil {
-				logger.Error("", err)
 				return nil, err
 			}
 			logger.Debug("", "New service created")
 
 			f, err := service.Drives.Get(fid).Do()
 			if err != nil {
+				lastErr = err
 				switch {
 				case utils.IsRateLimitError(err):
-					SaConfigs.DecSa()
+					rateLimited(saFile, retry, err)
 					continue
 				case utils.IsBackendError(err):
-					SaConfigs.MarkFinished(saFile)
+					releaseSa(saFile)
+					continue
+				case saFile != nil && utils.IsAuthError(err):
+					SaConfigs.Invalid(saFile, err)
 					continue
 				default:
-					SaConfigs.MarkFinished(saFile)
+					releaseSa(saFile)
 					return nil, err
 				}
 			}
-			SaConfigs.MarkFinished(saFile)
+			releaseSa(saFile)
 			return f, err
 		}
 	}
-	return nil, errors.New("no chance to drive call")
+	return nil, retriesExhausted("drive call", lastErr)
 }
 
 func fileGetCall(ctx context.Context, fid string, args ListArgs) (*drive.File, error) {
 	logger.Debug("%s - %s - %s", "FileGetCall request call args", fid, args)
+	var lastErr error
 	for retry := 0; retry <= config.RetryLimit; retry++ {
 		select {
 		case <-ctx.Done():
 			logger.Debug("", "Cancelled by user")
 			return nil, errors.Errorf("Cancelled by user")
 		default:
-			saFile, err := SaConfigs.UseSa()
+			service, saFile, err := newService(ctx)
 			if err != nil {
-				logger.Error("", err)
-			}
-			client := auth.NewServiceAccountClient(ctx, saFile)
-
-			service, err := drive.NewService(ctx, option.WithHTTPClient(client))
-			if err != nil {
-				logger.Error("", err)
 				return nil, err
 			}
 			logger.Debug("", "New service created")
 
 			f, err := service.Files.Get(fid).SupportsAllDrives(true).Fields(args.Fields...).Do()
 			if err != nil {
+				lastErr = err
 				switch {
 				case utils.IsRateLimitError(err):
-					SaConfigs.DecSa()
+					rateLimited(saFile, retry, err)
 					continue
 				case utils.IsBackendError(err):
-					SaConfigs.MarkFinished(saFile)
+					releaseSa(saFile)
+					continue
+				case saFile != nil && utils.IsAuthError(err):
+					SaConfigs.Invalid(saFile, err)
 					continue
 				default:
-					SaConfigs.MarkFinished(saFile)
+					releaseSa(saFile)
 					return nil, err
 				}
 			}
-			SaConfigs.MarkFinished(saFile)
+			releaseSa(saFile)
 			return f, err
 		}
 	}
-	return nil, errors.New("no chance to file get call")
+	return nil, retriesExhausted("file get call", lastErr)
 }
 
 func fileCreateCall(ctx context.Context, file *drive.File, args ListArgs) (*drive.File, error) {
 	logger.Debug("%s - %s - %s", "FileCreateCall request call args", file.Name, args)
+	var lastErr error
 	for retry := 0; retry <= config.RetryLimit; retry++ {
 		select {
 		case <-ctx.Done():
 			logger.Debug("", "Cancelled by user")
 			return nil, errors.Errorf("Cancelled by user")
 		default:
-			saFile, err := SaConfigs.UseSa()
+			service, saFile, err := newService(ctx)
 			if err != nil {
-				logger.Error("", err)
-			}
-
-			client := auth.NewServiceAccountClient(ctx, saFile)
-
-			service, err := drive.NewService(ctx, option.WithHTTPClient(client))
-			if err != nil {
-				logger.Error("", err)
 				return nil, err
 			}
 
 			f, err := service.Files.Create(file).SupportsAllDrives(args.supportsAllDrives).Do()
 			if err != nil {
+				lastErr = err
 				switch {
 				case utils.IsRateLimitError(err):
-					SaConfigs.DecSa()
+					rateLimited(saFile, retry, err)
 					continue
 				case utils.IsBackendError(err):
-					SaConfigs.MarkFinished(saFile)
+					releaseSa(saFile)
+					continue
+				case saFile != nil && utils.IsAuthError(err):
+					SaConfigs.Invalid(saFile, err)
 					continue
 				default:
-					SaConfigs.MarkFinished(saFile)
+					releaseSa(saFile)
 					return nil, err
 				}
 			}
-			SaConfigs.MarkFinished(saFile)
+			releaseSa(saFile)
 			return f, err
 		}
 	}
-	return nil, errors.New("no chance to file create call")
+	return nil, retriesExhausted("file create call", lastErr)
 }
 
 func fileListCall(ctx context.Context, args ListArgs) ([]*drive.File, error) {
@@ -149,89 +200,227 @@ func fileListCall(ctx context.Context, args ListArgs) ([]*drive.File, error) {
 	}
 
 	logger.Debug("%s - %s", "FileListCall request call args", args)
+	var lastErr error
 	for retry := 0; retry <= config.RetryLimit; retry++ {
 		select {
 		case <-ctx.Done():
 			logger.Debug("", "Cancelled by user")
 			return nil, errors.Errorf("Cancelled by user")
 		default:
-			saFile, err := SaConfigs.UseSa()
+			service, saFile, err := newService(ctx)
 			if err != nil {
-				logger.Error("", err)
-			}
-			client := auth.NewServiceAccountClient(ctx, saFile)
-
-			service, err := drive.NewService(ctx, option.WithHTTPClient(client))
-			if err != nil {
-				logger.Error("", err)
 				return nil, err
 			}
 			logger.Debug("", "New service created")
 
-			err = service.Files.List().IncludeItemsFromAllDrives(args.includeItemsFromAllDrives).
+			files = nil
+			call := service.Files.List().IncludeItemsFromAllDrives(args.includeItemsFromAllDrives).
 				SupportsAllDrives(args.supportsAllDrives).Q(args.Query).Fields(args.Fields...).
-				OrderBy(args.SortOrder).PageSize(pageSize).Pages(ctx, func(fileList *drive.FileList) error {
+				OrderBy(args.SortOrder).PageSize(pageSize)
+			if args.driveID != "" {
+				call = call.Corpora("drive").DriveId(args.driveID)
+			}
+			err = call.Pages(ctx, func(fileList *drive.FileList) error {
 				files = append(files, fileList.Files...)
 				return nil
 			})
 			if err != nil {
+				lastErr = err
 				switch {
 				case utils.IsRateLimitError(err):
-					SaConfigs.DecSa()
+					rateLimited(saFile, retry, err)
 					continue
 				case utils.IsBackendError(err):
-					SaConfigs.MarkFinished(saFile)
+					releaseSa(saFile)
+					continue
+				case saFile != nil && utils.IsAuthError(err):
+					SaConfigs.Invalid(saFile, err)
 					continue
 				default:
-					SaConfigs.MarkFinished(saFile)
+					releaseSa(saFile)
 					return nil, err
 				}
 			}
-			SaConfigs.MarkFinished(saFile)
+			releaseSa(saFile)
 			return files, err
 		}
 	}
-	return nil, errors.New("no chance to file list call")
+	return nil, retriesExhausted("file list call", lastErr)
 }
 
-func fileCopyCall(ctx context.Context, id, parent string, args ListArgs) (*drive.File, error) {
+// fileCopyCall copies the file, and returns the email of the Sa which copied it, which is empty for the user account.
+func fileCopyCall(ctx context.Context, id, parent string, args ListArgs) (*drive.File, string, error) {
 	logger.Debug("%s - ID: %s - Parent: %s - Args: %s", "fileCopyCall request call args", id, parent, args)
+	var lastErr error
 	for retry := 0; retry < config.RetryLimit; retry++ {
 		select {
 		case <-ctx.Done():
 			logger.Debug("", "Request cancelled by user.")
-			return nil, errors.Errorf("Cancelled by user")
+			return nil, "", errors.Errorf("Cancelled by user")
 		default:
-			saFile, err := SaConfigs.UseSa()
+			service, saFile, err := newService(ctx)
 			if err != nil {
-				logger.Error("", err)
+				return nil, "", err
 			}
-			client := auth.NewServiceAccountClient(ctx, saFile)
 
-			service, err := drive.NewService(ctx, option.WithHTTPClient(client))
+			f := &drive.File{Parents: []string{parent}}
+
+			file, err := service.Files.Copy(id, f).SupportsAllDrives(args.supportsAllDrives).Do()
 			if err != nil {
-				return nil, err
+				lastErr = err
+				switch {
+				case utils.IsRateLimitError(err):
+					rateLimited(saFile, retry, err)
+					continue
+				case utils.IsBackendError(err):
+					releaseSa(saFile)
+					continue
+				case saFile != nil && utils.IsAuthError(err):
+					SaConfigs.Invalid(saFile, err)
+					continue
+				default:
+					releaseSa(saFile)
+					return nil, "", err
+				}
 			}
+			releaseSa(saFile)
+			return file, accountEmail(saFile), err
+		}
+	}
+	return nil, "", retriesExhausted("file copy call", lastErr)
+}
 
-			f := &drive.File{Parents: []string{parent}}
+func fileUpdateCall(ctx context.Context, id string, file *drive.File, args ListArgs) (*drive.File, error) {
+	logger.Debug("%s - ID: %s - Args: %s", "fileUpdateCall request call args", id, args)
+	var lastErr error
+	for retry := 0; retry <= config.RetryLimit; retry++ {
+		select {
+		case <-ctx.Done():
+			logger.Debug("", "Cancelled by user")
+			return nil, errors.Errorf("Cancelled by user")
+		default:
+			service, saFile, err := newService(ctx)
+			if err != nil {
+				return nil, err
+			}
 
-			file, err := service.Files.Copy(id, f).SupportsAllDrives(args.supportsAllDrives).Do()
+			f, err := service.Files.Update(id, file).SupportsAllDrives(args.supportsAllDrives).Do()
 			if err != nil {
+				lastErr = err
 				switch {
 				case utils.IsRateLimitError(err):
-					SaConfigs.DecSa()
+					rateLimited(saFile, retry, err)
 					continue
 				case utils.IsBackendError(err):
-					SaConfigs.MarkFinished(saFile)
+					releaseSa(saFile)
+					continue
+				case saFile != nil && utils.IsAuthError(err):
+					SaConfigs.Invalid(saFile, err)
 					continue
 				default:
-					SaConfigs.MarkFinished(saFile)
+					releaseSa(saFile)
 					return nil, err
 				}
 			}
-			SaConfigs.MarkFinished(saFile)
-			return file, err
+			releaseSa(saFile)
+			return f, err
+		}
+	}
+	return nil, retriesExhausted("file update call", lastErr)
+}
+
+func changesStartTokenCall(ctx context.Context, driveID string) (string, error) {
+	logger.Debug("%s - %s", "changesStartTokenCall request call args", driveID)
+	var lastErr error
+	for retry := 0; retry <= config.RetryLimit; retry++ {
+		select {
+		case <-ctx.Done():
+			logger.Debug("", "Cancelled by user")
+			return "", errors.Errorf("Cancelled by user")
+		default:
+			service, saFile, err := newService(ctx)
+			if err != nil {
+				return "", err
+			}
+			logger.Debug("", "New service created")
+
+			token, err := service.Changes.GetStartPageToken().DriveId(driveID).SupportsAllDrives(true).Do()
+			if err != nil {
+				lastErr = err
+				switch {
+				case utils.IsRateLimitError(err):
+					rateLimited(saFile, retry, err)
+					continue
+				case utils.IsBackendError(err):
+					releaseSa(saFile)
+					continue
+				case saFile != nil && utils.IsAuthError(err):
+					SaConfigs.Invalid(saFile, err)
+					continue
+				default:
+					releaseSa(saFile)
+					return "", err
+				}
+			}
+			releaseSa(saFile)
+			return token.StartPageToken, err
+		}
+	}
+	return "", retriesExhausted("changes start token call", lastErr)
+}
+
+// changesListCall returns the changes in the shared drive since the token, along with the token of the
+// changes which are going to be made from now on.
+func changesListCall(ctx context.Context, token, driveID string, args ListArgs) ([]*drive.Change, string, error) {
+	var changes []*drive.Change
+	var newToken string
+
+	logger.Debug("%s - %s - %s", "changesListCall request call args", token, driveID)
+	var lastErr error
+	for retry := 0; retry <= config.RetryLimit; retry++ {
+		select {
+		case <-ctx.Done():
+			logger.Debug("", "Cancelled by user")
+			return nil, "", errors.Errorf("Cancelled by user")
+		default:
+			service, saFile, err := newService(ctx)
+			if err != nil {
+				return nil, "", err
+			}
+			logger.Debug("", "New service created")
+
+			changes = nil
+			for pageToken := token; pageToken != ""; {
+				var changeList *drive.ChangeList
+				changeList, err = service.Changes.List(pageToken).DriveId(driveID).IncludeItemsFromAllDrives(true).
+					SupportsAllDrives(true).IncludeRemoved(true).PageSize(1000).Fields(args.Fields...).Context(ctx).Do()
+				if err != nil {
+					break
+				}
+				changes = append(changes, changeList.Changes...)
+				pageToken = changeList.NextPageToken
+				newToken = changeList.NewStartPageToken
+			}
+			if err != nil {
+				lastErr = err
+				switch {
+				case utils.IsRateLimitError(err):
+					rateLimited(saFile, retry, err)
+					continue
+				case utils.IsBackendError(err):
+					releaseSa(saFile)
+					continue
+				case saFile != nil && utils.IsAuthError(err):
+					SaConfigs.Invalid(saFile, err)
+					continue
+				default:
+					releaseSa(saFile)
+					return nil, "", err
+				}
+			}
+			releaseSa(saFile)
+			return changes, newToken, err
 		}
 	}
-	return nil, errors.New("no chance to file copy call")
+	return nil, "", retriesExhausted("changes list call", lastErr)
 }
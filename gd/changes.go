@@ -0,0 +1,104 @@
+package gd
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/api/googleapi"
+
+	"github.com/xybydy/gdutils/database"
+	"github.com/xybydy/gdutils/logger"
+)
+
+// refreshCache applies the changes made in the shared drive since the last walk of fid to its cached tree.
+// If the tree is going to be listed online instead, the start of the changes is fetched before the listing,
+// and it is recorded by the returned func once the walk succeeds. Only the folders in shared drives are
+// tracked, the changes of My Drive belong to each service account separately.
+func refreshCache(ctx context.Context, fid string, notTeamdrive, update bool) func() {
+	noop := func() {}
+	if notTeamdrive || fid == "root" {
+		return noop
+	}
+
+	cached, err := db.GDExist(fid)
+	if err != nil {
+		logger.Error("", err)
+		return noop
+	}
+	if cached && !update {
+		applyCachedChanges(ctx, fid)
+		return noop
+	}
+
+	driveID, err := driveOf(ctx, fid)
+	if err != nil {
+		logger.Error("", err)
+		return noop
+	}
+	if driveID == "" {
+		return noop
+	}
+	token, err := changesStartTokenCall(ctx, driveID)
+	if err != nil {
+		logger.Error("", err)
+		return noop
+	}
+	return func() {
+		if err := db.ChangesSave(fid, driveID, token); err != nil {
+			logger.Error("", err)
+		}
+	}
+}
+
+// applyCachedChanges brings the cached tree of fid up to date, if the changes of its drive are tracked.
+func applyCachedChanges(ctx context.Context, fid string) {
+	record, exists, err := db.ChangesGet(fid)
+	if err != nil {
+		logger.Error("", err)
+		return
+	}
+	if !exists {
+		return
+	}
+	if err := applyChanges(ctx, record); err != nil {
+		logger.Error("Unable to apply the changes to the cache of %s: %s", fid, err)
+	}
+}
+
+func applyChanges(ctx context.Context, record database.ChangesDB) error {
+	args := ListArgs{}
+	args.Fields = []googleapi.Field{"nextPageToken", "newStartPageToken", "changes(fileId,removed,file(id,name,md5Checksum,mimeType,size,modifiedTime,parents,trashed))"}
+
+	changes, token, err := changesListCall(ctx, record.Token, record.DriveID, args)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	rows := make([]database.FileChange, 0, len(changes))
+	for _, i := range changes {
+		// the changes of the shared drive itself have no file
+		if i.FileId == "" {
+			continue
+		}
+		change := database.FileChange{ID: i.FileId}
+		if i.Removed || i.File == nil || i.File.Trashed || len(i.File.Parents) == 0 {
+			change.Removed = true
+		} else {
+			change.File = database.NewFileDB(i.File.Parents[0], i.File, now)
+		}
+		rows = append(rows, change)
+	}
+
+	applied, err := db.FilesApplyChanges(rows)
+	if err != nil {
+		return err
+	}
+	if applied > 0 {
+		logger.Info("%d changes are applied to the cache of %s", applied, record.Fid)
+		if err := db.GDClearDriveSummaries(record.DriveID); err != nil {
+			logger.Error("", err)
+		}
+	}
+	return db.ChangesSave(record.Fid, record.DriveID, token)
+}
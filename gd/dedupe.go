@@ -0,0 +1,247 @@
+package gd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"go.uber.org/ratelimit"
+	"google.golang.org/api/drive/v3"
+
+	"github.com/xybydy/gdutils/counter"
+	"github.com/xybydy/gdutils/logger"
+	"github.com/xybydy/gdutils/prompter"
+	"github.com/xybydy/gdutils/status"
+	"github.com/xybydy/gdutils/summary"
+)
+
+// Dedupe walks the given folder, trashes the files having the same md5 and size with another file
+// in the tree, and the folders which have nothing inside.
+func Dedupe(ctx context.Context, fid string, yes, notTeamdrive bool) error {
+	logger.Debugw("Dedupe operation started", "fid", fid, "yes", yes, "notTeamdrive", notTeamdrive)
+
+	if !validateFid(fid) {
+		return errors.New("invalid folder id")
+	}
+
+	// The tree is always listed online, a stale cache may hold a kept copy which is already gone, or an
+	// empty folder which has got content since.
+	arr, err := walkAndSave(ctx, fid, notTeamdrive, true, false)
+	if err != nil {
+		return err
+	}
+
+	duplicates := findDuplicates(arr)
+	emptyFolders := findEmptyFolders(arr)
+	fmt.Printf("\nDuplicate files: %d, Empty folders: %d\n", len(duplicates), len(emptyFolders))
+	logger.Info("Duplicate files: %d, Empty folders: %d", len(duplicates), len(emptyFolders))
+
+	if len(duplicates) == 0 && len(emptyFolders) == 0 {
+		return nil
+	}
+
+	if !yes {
+		confirmed, err := prompter.PromptDuplicate(len(duplicates), len(emptyFolders))
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			logger.Debug("", "Deletion is not confirmed")
+			return nil
+		}
+	}
+
+	trashed := trashFiles(ctx, append(duplicates, emptyFolders...))
+	fmt.Printf("\nItems trashed: %d\n", len(trashed))
+
+	refreshParents(ctx, parentIDs(trashed), notTeamdrive)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	remaining := excludeFiles(arr, trashed)
+	smy := summary.Summary(remaining, "")
+	if err := db.GDUpdateSummary(fid, smy.String()); err != nil {
+		logger.Error("", err)
+	}
+	return nil
+}
+
+// findDuplicates groups the files by md5 and size and returns all but one file of each group.
+// Files without md5Checksum such as Google Docs are never treated as duplicates.
+func findDuplicates(arr []*drive.File) []*drive.File {
+	groups := make(map[string][]*drive.File)
+	for _, i := range filterFiles(arr, 0) {
+		if i.Md5Checksum == "" {
+			continue
+		}
+		key := fmt.Sprintf("%s %d", i.Md5Checksum, i.Size)
+		groups[key] = append(groups[key], i)
+	}
+
+	duplicates := make([]*drive.File, 0)
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool {
+			if group[i].Name == group[j].Name {
+				return group[i].Id < group[j].Id
+			}
+			return group[i].Name < group[j].Name
+		})
+		duplicates = append(duplicates, group[1:]...)
+	}
+	return duplicates
+}
+
+// findEmptyFolders returns the topmost folders that have nothing but empty folders inside, the folders
+// under them are trashed along with them.
+func findEmptyFolders(arr []*drive.File) []*drive.File {
+	children := make(map[string][]*drive.File)
+	byID := make(map[string]*drive.File)
+	for _, i := range arr {
+		byID[i.Id] = i
+		if len(i.Parents) > 0 {
+			children[i.Parents[0]] = append(children[i.Parents[0]], i)
+		}
+	}
+
+	empty := make(map[string]bool)
+	var isEmpty func(folder *drive.File) bool
+	isEmpty = func(folder *drive.File) bool {
+		if e, ok := empty[folder.Id]; ok {
+			return e
+		}
+		e := true
+		for _, i := range children[folder.Id] {
+			if i.MimeType != FolderType || !isEmpty(i) {
+				e = false
+			}
+		}
+		empty[folder.Id] = e
+		return e
+	}
+
+	folders := make([]*drive.File, 0)
+	for _, i := range filterFolders(arr) {
+		if !isEmpty(i) {
+			continue
+		}
+		if len(i.Parents) > 0 {
+			if parent, ok := byID[i.Parents[0]]; ok && parent.MimeType == FolderType && isEmpty(parent) {
+				continue
+			}
+		}
+		folders = append(folders, i)
+	}
+	return folders
+}
+
+func excludeFiles(arr, excluded []*drive.File) []*drive.File {
+	ids := make(map[string]bool)
+	for _, i := range excluded {
+		ids[i.Id] = true
+	}
+
+	files := make([]*drive.File, 0)
+	for _, i := range arr {
+		if !ids[i.Id] {
+			files = append(files, i)
+		}
+	}
+	return files
+}
+
+func trashFile(ctx context.Context, id string) (*drive.File, error) {
+	args := ListArgs{supportsAllDrives: true}
+	return fileUpdateCall(ctx, id, &drive.File{Trashed: true}, args)
+}
+
+func trashFiles(ctx context.Context, files []*drive.File) []*drive.File {
+	logger.Info("Started trashing items, total：%d", len(files))
+	return processFiles(ctx, files, status.StatusTrash, func(ctx context.Context, item *drive.File) error {
+		_, err := trashFile(ctx, item.Id)
+		if err != nil {
+			logger.Error("Unable to trash %s: %s", item.Id, err)
+		}
+		return err
+	})
+}
+
+// processFiles runs fn for each file in parallel, and returns the files fn succeeded for.
+func processFiles(ctx context.Context, files []*drive.File, statusType int, fn func(context.Context, *drive.File) error) []*drive.File {
+	var wg sync.WaitGroup
+	var mut sync.Mutex
+	var done []*drive.File
+	var count = new(counter.Counter)
+	var pendingCount = new(counter.Counter)
+	ctx, cancel := context.WithCancel(ctx)
+	limiter := ratelimit.New(100)
+	defer cancel()
+
+	if len(files) == 0 {
+		return done
+	}
+	go status.PrintStatus(ctx, pendingCount, count, statusType)
+
+	pendingCount.Set(int32(len(files)))
+	for _, item := range files {
+		wg.Add(1)
+		go func(innerItem *drive.File) {
+			sema.Wait()
+			defer wg.Done()
+			defer func() {
+				sema.Signal()
+			}()
+
+			if ctx.Err() != nil {
+				pendingCount.Dec()
+				return
+			}
+			limiter.Take()
+			err := fn(ctx, innerItem)
+			pendingCount.Dec()
+			if err != nil {
+				return
+			}
+
+			count.Inc()
+			mut.Lock()
+			done = append(done, innerItem)
+			mut.Unlock()
+		}(item)
+	}
+	wg.Wait()
+	cancel()
+	return done
+}
+
+// refreshParents lists the given folders again so that the cache does not keep the removed items.
+func refreshParents(ctx context.Context, parents map[string]bool, notTeamdrive bool) {
+	limiter := ratelimit.New(100)
+	for parent := range parents {
+		if ctx.Err() != nil {
+			return
+		}
+		limiter.Take()
+		files, err := lsFolder(ctx, parent, notTeamdrive, false)
+		if err != nil {
+			logger.Error("", err)
+			continue
+		}
+		saveFilesToDB(parent, files)
+	}
+}
+
+func parentIDs(files []*drive.File) map[string]bool {
+	parents := make(map[string]bool)
+	for _, i := range files {
+		if len(i.Parents) > 0 {
+			parents[i.Parents[0]] = true
+		}
+	}
+	return parents
+}
@@ -0,0 +1,48 @@
+package gd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/api/drive/v3"
+)
+
+type DedupeSuite struct {
+	suite.Suite
+}
+
+func (suite *DedupeSuite) TestFindEmptyFolders() {
+	arr := []*drive.File{
+		testFolder("c", "c", "b"),
+		testFolder("b", "b", "a"),
+		testFolder("a", "a", "root"),
+		testFolder("d", "d", "a"),
+		testFolder("e", "e", "root"),
+		testFile("f", "f.txt", "e", 1, "x"),
+		testFolder("g", "g", "e"),
+	}
+
+	var ids []string
+	for _, i := range findEmptyFolders(arr) {
+		ids = append(ids, i.Id)
+	}
+	suite.ElementsMatch([]string{"a", "g"}, ids)
+}
+
+func (suite *DedupeSuite) TestFindDuplicates() {
+	arr := []*drive.File{
+		testFile("1", "b.txt", "root", 1, "x"),
+		testFile("2", "a.txt", "root", 1, "x"),
+		testFile("3", "c.txt", "root", 2, "x"),
+		{Id: "4", Name: "doc", Parents: []string{"root"}},
+		{Id: "5", Name: "doc", Parents: []string{"root"}},
+	}
+
+	duplicates := findDuplicates(arr)
+	suite.Len(duplicates, 1)
+	suite.Equal("1", duplicates[0].Id)
+}
+
+func TestDedupeSuite(t *testing.T) {
+	suite.Run(t, new(DedupeSuite))
+}
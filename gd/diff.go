@@ -0,0 +1,188 @@
+package gd
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"google.golang.org/api/drive/v3"
+
+	"github.com/xybydy/gdutils/logger"
+	"github.com/xybydy/gdutils/summary"
+)
+
+const (
+	DiffAdded    = "+"
+	DiffRemoved  = "-"
+	DiffModified = "M"
+	DiffRenamed  = "R"
+)
+
+type diffEntry struct {
+	Kind    string
+	Path    string
+	NewPath string
+}
+
+func (d diffEntry) String() string {
+	if d.Kind == DiffRenamed {
+		return fmt.Sprintf("%s %s -> %s", d.Kind, d.Path, d.NewPath)
+	}
+	return fmt.Sprintf("%s %s", d.Kind, d.Path)
+}
+
+// Diff prints the added, removed, renamed and modified items between two folders or snapshot files
+// created with count -t snap. If b is empty, the cached tree of a is compared with its current state,
+// and the cache is refreshed afterwards.
+func Diff(ctx context.Context, a, b string, update, notTeamdrive bool) error {
+	logger.Debugw("Diff operation started", "a", a, "b", b, "update", update, "notTeamdrive", notTeamdrive)
+
+	var rootA, rootB string
+	var arrA, arrB []*drive.File
+	var err error
+	if b == "" {
+		if !validateFid(a) {
+			return errors.New("invalid folder id")
+		}
+		rootA, rootB = a, a
+		arrA, err = getAllByFid(a)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if len(arrA) == 0 || errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("no complete cached data found for %s", a)
+		}
+		arrB, err = walkAndSave(ctx, a, notTeamdrive, true, false)
+		if err != nil {
+			return err
+		}
+	} else {
+		rootA, arrA, err = loadTree(ctx, a, update, notTeamdrive)
+		if err != nil {
+			return err
+		}
+		rootB, arrB, err = loadTree(ctx, b, update, notTeamdrive)
+		if err != nil {
+			return err
+		}
+	}
+
+	entries := diffTrees(rootA, rootB, arrA, arrB)
+	fmt.Println()
+	for _, i := range entries {
+		fmt.Println(i)
+	}
+	fmt.Println(diffSummary(entries))
+	return nil
+}
+
+// loadTree reads the tree either from a snapshot file or from the folder with the given id.
+func loadTree(ctx context.Context, arg string, update, notTeamdrive bool) (string, []*drive.File, error) {
+	if info, err := os.Stat(arg); err == nil && !info.IsDir() {
+		logger.Debug("Reading snapshot %s", arg)
+		snap, err := summary.ReadSnapshot(arg)
+		if err != nil {
+			return "", nil, err
+		}
+		return snap.Root, snap.Files, nil
+	}
+
+	if !validateFid(arg) {
+		return "", nil, fmt.Errorf("invalid folder id or snapshot file: %s", arg)
+	}
+	arr, err := walkAndSave(ctx, arg, notTeamdrive, update, false)
+	return arg, arr, err
+}
+
+func diffSummary(entries []diffEntry) string {
+	counts := make(map[string]int)
+	for _, i := range entries {
+		counts[i.Kind]++
+	}
+	return fmt.Sprintf("Added: %d, Removed: %d, Modified: %d, Renamed: %d",
+		counts[DiffAdded], counts[DiffRemoved], counts[DiffModified], counts[DiffRenamed])
+}
+
+// diffTrees pairs the items of the trees by id first, which finds the renames within the same folder
+// over time, then by relative path, and finally by md5 for the files moved between two different trees.
+func diffTrees(rootA, rootB string, a, b []*drive.File) []diffEntry {
+	pathsA := relativePaths(rootA, a)
+	pathsB := relativePaths(rootB, b)
+	var entries []diffEntry
+
+	byIDB := make(map[string]*drive.File, len(b))
+	byPathB := make(map[string]*drive.File, len(b))
+	for _, i := range b {
+		byIDB[i.Id] = i
+		byPathB[diffKey(pathsB[i.Id], i)] = i
+	}
+
+	pairedB := make(map[string]bool)
+	var removed []*drive.File
+	for _, i := range a {
+		j, ok := byIDB[i.Id]
+		if !ok {
+			j, ok = byPathB[diffKey(pathsA[i.Id], i)]
+		}
+		if !ok || pairedB[j.Id] {
+			removed = append(removed, i)
+			continue
+		}
+		pairedB[j.Id] = true
+
+		if pathsA[i.Id] != pathsB[j.Id] {
+			entries = append(entries, diffEntry{Kind: DiffRenamed, Path: pathsA[i.Id], NewPath: pathsB[j.Id]})
+		}
+		if i.MimeType != FolderType && !sameContent(i, j) {
+			entries = append(entries, diffEntry{Kind: DiffModified, Path: pathsB[j.Id]})
+		}
+	}
+
+	added := make(map[string][]*drive.File)
+	var addedOrder []*drive.File
+	for _, i := range b {
+		if pairedB[i.Id] {
+			continue
+		}
+		addedOrder = append(addedOrder, i)
+		if i.Md5Checksum != "" {
+			key := fmt.Sprintf("%s %d", i.Md5Checksum, i.Size)
+			added[key] = append(added[key], i)
+		}
+	}
+
+	for _, i := range removed {
+		if i.Md5Checksum != "" {
+			key := fmt.Sprintf("%s %d", i.Md5Checksum, i.Size)
+			if candidates := added[key]; len(candidates) > 0 {
+				j := candidates[0]
+				added[key] = candidates[1:]
+				pairedB[j.Id] = true
+				entries = append(entries, diffEntry{Kind: DiffRenamed, Path: pathsA[i.Id], NewPath: pathsB[j.Id]})
+				continue
+			}
+		}
+		entries = append(entries, diffEntry{Kind: DiffRemoved, Path: pathsA[i.Id]})
+	}
+
+	for _, i := range addedOrder {
+		if !pairedB[i.Id] {
+			entries = append(entries, diffEntry{Kind: DiffAdded, Path: pathsB[i.Id]})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	return entries
+}
+
+func diffKey(p string, f *drive.File) string {
+	if f.MimeType == FolderType {
+		return p + "/"
+	}
+	return p
+}
@@ -0,0 +1,68 @@
+package gd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/api/drive/v3"
+)
+
+type DiffSuite struct {
+	suite.Suite
+}
+
+func (suite *DiffSuite) TestDiffTreesOverTime() {
+	before := []*drive.File{
+		testFile("1", "same.txt", "root", 1, "a"),
+		testFile("2", "old-name.txt", "root", 2, "b"),
+		testFile("3", "changed.txt", "root", 3, "c"),
+		testFile("4", "removed.txt", "root", 4, "d"),
+	}
+	after := []*drive.File{
+		testFile("1", "same.txt", "root", 1, "a"),
+		testFile("2", "new-name.txt", "root", 2, "b"),
+		testFile("3", "changed.txt", "root", 30, "cc"),
+		testFile("5", "added.txt", "root", 5, "e"),
+	}
+
+	suite.Equal([]diffEntry{
+		{Kind: DiffModified, Path: "changed.txt"},
+		{Kind: DiffRenamed, Path: "old-name.txt", NewPath: "new-name.txt"},
+		{Kind: DiffRemoved, Path: "removed.txt"},
+		{Kind: DiffAdded, Path: "added.txt"},
+	}, sortedByKind(diffTrees("root", "root", before, after)))
+}
+
+func (suite *DiffSuite) TestDiffTreesBetweenFolders() {
+	a := []*drive.File{
+		testFolder("fa", "dir", "a"),
+		testFile("1", "moved.txt", "fa", 1, "m"),
+		testFile("2", "kept.txt", "a", 2, "k"),
+	}
+	b := []*drive.File{
+		testFolder("fb", "dir", "b"),
+		testFile("11", "moved.txt", "b", 1, "m"),
+		testFile("12", "kept.txt", "b", 2, "k"),
+	}
+
+	suite.Equal([]diffEntry{
+		{Kind: DiffRenamed, Path: "dir/moved.txt", NewPath: "moved.txt"},
+	}, diffTrees("a", "b", a, b))
+}
+
+func sortedByKind(entries []diffEntry) []diffEntry {
+	order := map[string]int{DiffModified: 0, DiffRenamed: 1, DiffRemoved: 2, DiffAdded: 3}
+	sorted := make([]diffEntry, 0, len(entries))
+	for k := 0; k < len(order); k++ {
+		for _, i := range entries {
+			if order[i.Kind] == k {
+				sorted = append(sorted, i)
+			}
+		}
+	}
+	return sorted
+}
+
+func TestDiffSuite(t *testing.T) {
+	suite.Run(t, new(DiffSuite))
+}
@@ -0,0 +1,110 @@
+package gd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/xybydy/gdutils/database"
+	"github.com/xybydy/gdutils/logger"
+	"github.com/xybydy/gdutils/summary"
+)
+
+// Du prints the sizes and the file counts of the folders under fid rolled up through the hierarchy.
+// The usage is calculated from the cache, the missing parts are walked first.
+func Du(ctx context.Context, fid, sort, outType, output string, depth, top int, update, notTeamdrive bool) error {
+	logger.Debugw("Du operation started", "fid", fid, "sort", sort, "outType", outType, "output", output, "depth", depth, "top", top, "update", update, "notTeamdrive", notTeamdrive)
+
+	if !validateFid(fid) {
+		return errors.New("invalid folder id")
+	}
+
+	items, err := folderUsage(fid)
+	if update || errors.Is(err, sql.ErrNoRows) || cacheExpired(fid) {
+		logger.Debug("Cache of %s is not complete, walking the folder", fid)
+		if _, err := walkAndSave(ctx, fid, notTeamdrive, update, false); err != nil {
+			return err
+		}
+		items, err = folderUsage(fid)
+	}
+	if err != nil {
+		return err
+	}
+
+	items = summary.FilterDu(items, depth, top, strings.ToLower(sort))
+	var outStr string
+	switch strings.ToLower(outType) {
+	case "html":
+		outStr = summary.MakeDuHTML(items)
+	default:
+		outStr = summary.MakeDuTable(items)
+	}
+
+	if output != "" {
+		return ioutil.WriteFile(output, []byte(outStr), 0666)
+	}
+	fmt.Println()
+	fmt.Println(outStr)
+	return nil
+}
+
+// folderUsage follows the sub folders stored in the subf column of the cache, and returns the usage
+// of each folder with the root being the first.
+func folderUsage(fid string) ([]summary.DuItem, error) {
+	var items []summary.DuItem
+	var recur func(id, path string, depth int) (summary.DuItem, error)
+	recur = func(id, path string, depth int) (summary.DuItem, error) {
+		item := summary.DuItem{ID: id, Path: path, Depth: depth}
+		row, exists, err := db.GDGet(id)
+		if err != nil {
+			return item, err
+		}
+		if !exists {
+			return item, sql.ErrNoRows
+		}
+
+		index := len(items)
+		items = append(items, item)
+
+		var subf database.GdSubf
+		if row.Subf.Valid && row.Subf.String != "" {
+			if err := json.Unmarshal([]byte(row.Subf.String), &subf); err != nil {
+				return item, err
+			}
+		}
+
+		children, err := getCachedFiles(id)
+		if err != nil {
+			return item, err
+		}
+
+		names := make(map[string]string)
+		for _, i := range children {
+			if i.MimeType == FolderType {
+				names[i.Id] = i.Name
+				continue
+			}
+			item.Files++
+			item.Size += i.Size
+		}
+
+		for _, i := range subf {
+			child, err := recur(i, path+"/"+names[i], depth+1)
+			if err != nil {
+				return item, err
+			}
+			item.Files += child.Files
+			item.Folders += child.Folders + 1
+			item.Size += child.Size
+		}
+		items[index] = item
+		return item, nil
+	}
+
+	_, err := recur(fid, fid, 0)
+	return items, err
+}
@@ -14,6 +14,7 @@ import (
 
 	_ "github.com/mattn/go-sqlite3"
 	"go.uber.org/ratelimit"
+	"golang.org/x/oauth2"
 	"google.golang.org/api/drive/v3"
 	"google.golang.org/api/googleapi"
 
@@ -30,7 +31,6 @@ import (
 )
 
 // TODO: Proxy Support
-// TODO: Handle Exit
 
 // Queries per day 1,000,000,000
 // Queries per 100 seconds per user 1,000
@@ -42,10 +42,12 @@ type ListArgs struct {
 	Query                     string
 	includeItemsFromAllDrives bool
 	supportsAllDrives         bool
+	// driveID lists all the items of the shared drive, instead of the matching items in all drives
+	driveID string
 }
 
 func (l ListArgs) String() string {
-	return fmt.Sprintf("fields: %v, sortOrder: %s, query: %s, includeItemsFromAllDrives: %t, supportsAllDrives: %t", l.Fields, l.SortOrder, l.Query, l.includeItemsFromAllDrives, l.supportsAllDrives)
+	return fmt.Sprintf("fields: %v, sortOrder: %s, query: %s, includeItemsFromAllDrives: %t, supportsAllDrives: %t, driveID: %s", l.Fields, l.SortOrder, l.Query, l.includeItemsFromAllDrives, l.supportsAllDrives, l.driveID)
 }
 
 const (
@@ -54,16 +56,58 @@ const (
 
 var (
 	SaConfigs = new(auth.SaFileOrganizer)
+	// userToken is the user account logged in with "auth login", it is used if there are no Sa files
+	userToken oauth2.TokenSource
 	db        *database.DriveDB
 	sema      = semaphore.New(config.ParallelLimit)
 )
 
 func InitApp() {
-	logger.Debug("Connecting to db: %s", config.DBPath)
-	db = database.ConnectDB("sqlite3", config.DBPath)
+	InitDB()
 	logger.Debug("", "Service accounts initilization started")
+	loadSaUsage()
 	err := SaConfigs.InitFiles(config.SaLocation)
 	utils.CheckErr(err)
+	if SaConfigs.Len() > 0 {
+		return
+	}
+
+	logger.Debug("No valid Sa files found in %s, using the user account", config.SaLocation)
+	userToken, err = auth.NewUserTokenSource(context.Background(), config.OAuthClientFile, config.TokenFile)
+	if errors.Is(err, auth.ErrNotLoggedIn) {
+		err = fmt.Errorf("no valid Sa files found in %q and %w, run \"gdutils auth login\" to use a user account", config.SaLocation, err)
+	}
+	utils.CheckErr(err)
+}
+
+// Login logs the user account in, its token is used when there are no Sa files.
+func Login(ctx context.Context) error {
+	if err := auth.Login(ctx, config.OAuthClientFile, config.TokenFile); err != nil {
+		return err
+	}
+	fmt.Printf("Logged in, the token is saved to %s\n", config.TokenFile)
+	return nil
+}
+
+// loadSaUsage gives the bytes copied today by each Sa to SaConfigs, so the Sa files near their daily quota are skipped.
+func loadSaUsage() {
+	day := auth.UsageDay(time.Now())
+	rows, err := db.SaUsageGet(day)
+	if err != nil {
+		logger.Error("", err)
+		return
+	}
+	usage := make(map[string]int64, len(rows))
+	for _, i := range rows {
+		usage[i.Email] = i.Bytes
+	}
+	SaConfigs.SetUsage(day, usage)
+}
+
+// InitDB connects to the db only, for the operations which do not call drive api.
+func InitDB() {
+	logger.Debug("Connecting to db: %s", config.DBPath)
+	db = database.ConnectDB("sqlite3", config.DBPath)
 }
 
 func filterAll(arr []*drive.File, minSize int) ([]*drive.File, []*drive.File) {
@@ -96,11 +140,13 @@ func filterFolders(arr []*drive.File) []*drive.File {
 	return folders
 }
 
-func saveMd5(ctx context.Context, fid string, size int64, notTeamdrive bool, update bool) {
+func saveMd5(ctx context.Context, fid string, size int64, notTeamdrive bool, update bool) (int, error) {
 	logger.Debug("", "starting saving md5 hashes")
 	var count counter.Counter
 	f, err := walkAndSave(ctx, fid, notTeamdrive, update, false)
-	utils.CheckErr(err)
+	if err != nil {
+		return 0, err
+	}
 
 	for _, i := range f {
 		if i.MimeType != FolderType && i.Size >= size {
@@ -112,18 +158,20 @@ func saveMd5(ctx context.Context, fid string, size int64, notTeamdrive bool, upd
 			if err != nil {
 				logger.Error("", err)
 			}
-			if !exists {
+			if exists {
 				continue
 			}
 
 			err = db.HashAdd(i.Id, i.Md5Checksum)
 			if err != nil {
 				logger.Error("", err)
+				continue
 			}
 			count.Inc()
 		}
 	}
 	logger.Debug("%d no of hashes recorded", count.Get())
+	return int(count.Get()), nil
 }
 
 func validateFid(fid string) bool {
@@ -147,10 +195,8 @@ func validateFid(fid string) bool {
 	return matched
 }
 
-func getGIDByMd5(md5 string) string {
-	gid, err := db.HashGetID(md5)
-	logger.Error("", err)
-	return gid
+func getGIDByMd5(md5 string) ([]string, error) {
+	return db.HashGetIDs(strings.ToLower(md5))
 }
 
 func getDriveName(ctx context.Context, fid string) (string, error) {
@@ -162,61 +208,75 @@ func getDriveName(ctx context.Context, fid string) (string, error) {
 
 func saveFilesToDB(fid string, files []*drive.File) {
 	logger.Debug("", "saving file infos to DB")
-	var subf []string
+	folder := newCachedFolder(fid, files, time.Now().Unix())
+	if err := db.FilesReplaceFolders([]database.CachedFolder{folder}); err != nil {
+		logger.Error("", err)
+	}
+}
+
+func newCachedFolder(fid string, files []*drive.File, now int64) database.CachedFolder {
+	subf := make([]string, 0)
+	rows := make([]database.FileDB, 0, len(files))
 	for _, i := range files {
 		if i.MimeType == FolderType {
 			subf = append(subf, i.Id)
 		}
+		rows = append(rows, database.NewFileDB(fid, i, now))
 	}
 
-	filesJSON, err := json.Marshal(files)
+	subfJSON, err := json.Marshal(subf)
 	if err != nil {
 		logger.Error("", err)
 	}
-	if string(filesJSON) == "null" {
-		filesJSON = []byte("[]")
-	}
-
-	var subfJSON []byte
-	if len(subf) > 0 {
-		subfJSON, err = json.Marshal(subf)
-		if err != nil {
-			logger.Error("", err)
-		}
-	} else {
-		subfJSON = []byte("[]")
-	}
+	return database.CachedFolder{Fid: fid, Subf: string(subfJSON), Files: rows}
+}
 
-	exists, err := db.GDExist(fid)
+func getCachedFiles(parent string) ([]*drive.File, error) {
+	rows, err := db.FilesGetChildren(parent)
 	if err != nil {
-		logger.Error("", err)
-		return
+		return nil, err
 	}
+	files := make([]*drive.File, 0, len(rows))
+	for _, i := range rows {
+		files = append(files, i.ToFile())
+	}
+	return files, nil
+}
 
-	if exists {
-		err := db.GDUpdateItem(fid, string(filesJSON), string(subfJSON))
+// listChildren returns the items in the folder from the cache, or lists the folder if update is set or it is not
+// cached yet, or the cache policy does not allow its cached items to be used. The listed folders are saved into the cache.
+func listChildren(ctx context.Context, parent string, notTeamdrive, update, withModified bool, limiter ratelimit.Limiter) ([]*drive.File, error) {
+	if !update {
+		logger.Debug("Getting '%s' from db", parent)
+		record, exists, err := db.GDGet(parent)
 		if err != nil {
 			logger.Error("", err)
 		}
-	} else {
-		err := db.GDInsertItem(fid, string(filesJSON), string(subfJSON))
-		if err != nil {
-			logger.Error("", err)
+		if exists && cache.fresh(ctx, record) {
+			logger.Debug("%s found on the db", parent)
+			return getCachedFiles(record.Fid.String)
 		}
+		logger.Debug("%s NOT found on the db or it is outdated", parent)
 	}
+
+	limiter.Take()
+	files, err := lsFolder(ctx, parent, notTeamdrive, withModified)
+	if err != nil {
+		return nil, err
+	}
+	saveFilesToDB(parent, files)
+	return files, nil
 }
 
 func walkAndSave(ctx context.Context, fid string, notTeamdrive, update, withModified bool) ([]*drive.File, error) {
-	var resultMutex sync.Mutex
-	var result []*drive.File
-	var resultCount = new(counter.Counter)
-	var recur func()
+	commit := refreshCache(ctx, fid, notTeamdrive, update)
+	return walkTree(ctx, fid, notTeamdrive, update, withModified, commit)
+}
+
+// walkTree walks the tree of fid whose cache is already refreshed, commit records the start of the changes
+// once the walk succeeds.
+func walkTree(ctx context.Context, fid string, notTeamdrive, update, withModified bool, commit func()) ([]*drive.File, error) {
 	now := time.Now()
-	jobs := make(chan string, 10)
-	wg := new(sync.WaitGroup)
-	var pendingCount = new(counter.Counter)
-	limiter := ratelimit.New(100)
-	ctx, cancel := context.WithCancel(ctx)
 
 	logger.Debug("%s: %s", "Walking the directory", fid)
 
@@ -224,7 +284,6 @@ func walkAndSave(ctx context.Context, fid string, notTeamdrive, update, withModi
 		logger.Debug("", "Updating the existing db records")
 		exist, err := db.GDExist(fid)
 		if err != nil {
-			defer cancel()
 			return nil, err
 		}
 		if exist {
@@ -237,46 +296,63 @@ func walkAndSave(ctx context.Context, fid string, notTeamdrive, update, withModi
 		}
 	}
 
+	var result []*drive.File
+	var err error
+	if useDriveListing(ctx, fid, notTeamdrive, update) {
+		result, err = walkDrive(ctx, fid, withModified)
+	} else {
+		result, err = walkFolders(ctx, fid, notTeamdrive, update, withModified)
+	}
+	// The folders listed so far are saved, the summary of a partial walk is not.
+	if err != nil {
+		return nil, err
+	}
+	commit()
+
+	smy := summary.Summary(result, "")
+	if !smy.IsEmpty() {
+		err := db.GDUpdateSummary(fid, smy.String())
+		if err != nil {
+			logger.Error("", err)
+		}
+	}
+
+	logger.Info("Walking directory time took: %v", time.Since(now))
+	logger.Info("Result no: %d", len(result))
+	return result, nil
+}
+
+// walkFolders lists the tree folder by folder.
+func walkFolders(ctx context.Context, fid string, notTeamdrive, update, withModified bool) ([]*drive.File, error) {
+	var resultMutex sync.Mutex
+	var result []*drive.File
+	var resultCount = new(counter.Counter)
+	var recur func()
+	jobs := make(chan string, 10)
+	wg := new(sync.WaitGroup)
+	var pendingCount = new(counter.Counter)
+	limiter := ratelimit.New(100)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	go status.PrintStatus(ctx, pendingCount, resultCount, status.StatusReadPath)
 
 	recur = func() {
-		var shouldSave bool
-		var files []*drive.File
-		var err error
-
 		defer wg.Done()
 		defer func() {
 			pendingCount.Dec()
 		}()
 
 		parent := <-jobs
-
-		if update {
-			limiter.Take()
-			files, err = lsFolder(ctx, parent, notTeamdrive, withModified)
-			utils.CheckErr(err)
-			shouldSave = true
-		} else {
-			logger.Debug("Getting '%s' from db", parent)
-			record, exists, err := db.GDGet(parent)
-			if err != nil {
-				logger.Error("", err)
-			}
-			if exists {
-				logger.Debug("%s found on the db", parent)
-				err = json.Unmarshal([]byte(record.Info.String), &files)
-				utils.CheckErr(err)
-			} else {
-				logger.Debug("%s NOT found on the db", parent)
-				files, err = lsFolder(ctx, parent, notTeamdrive, withModified)
-				utils.CheckErr(err)
-				shouldSave = true
-			}
+		if ctx.Err() != nil {
+			return
 		}
 
-		if shouldSave {
-			saveFilesToDB(parent, files)
+		files, err := listChildren(ctx, parent, notTeamdrive, update, withModified, limiter)
+		if err != nil && ctx.Err() != nil {
+			return
 		}
+		utils.CheckErr(err)
 
 		folders := make(chan *drive.File, len(files))
 		for _, j := range files {
@@ -303,18 +379,9 @@ func walkAndSave(ctx context.Context, fid string, notTeamdrive, update, withModi
 	wg.Add(1)
 	recur()
 	wg.Wait()
-	cancel()
-
-	smy := summary.Summary(result, "")
-	if !smy.IsEmpty() {
-		err := db.GDUpdateSummary(fid, smy.String())
-		if err != nil {
-			logger.Error("", err)
-		}
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-
-	logger.Info("Walking directory time took: %v", time.Since(now))
-	logger.Info("Result no: %d", len(result))
 	return result, nil
 }
 
@@ -354,80 +421,32 @@ func getInfoByID(ctx context.Context, fid string) (*drive.File, error) {
 
 func getAllByFid(fid string) ([]*drive.File, error) {
 	logger.Debug("", "Getting all from id for", fid)
-	var recur func([]*drive.File, database.GdSubf) ([]*drive.File, error)
-	recur = func(result []*drive.File, subf database.GdSubf) ([]*drive.File, error) {
-		type res struct {
-			Info []*drive.File
-			Subf database.GdSubf
-		}
-		var ress []res
-		var subSubf database.GdSubf
-		if len(subf) == 0 {
-			return result, nil
-		}
-
-		for i := range subf {
-			var row database.GdDB
-			var info []*drive.File
-			var innerSubf database.GdSubf
-
-			row, exists, err := db.GDGet(subf[i])
-			// err := db.Get(&row, "SELECT * FROM gd WHERE fid = ? LIMIT 1", subf[i])
-			if err != nil {
-				logger.Error("", err)
-			}
-			if !exists {
-				return result, sql.ErrNoRows
-			}
-
-			err = json.Unmarshal([]byte(row.Info.String), &info)
-			utils.CheckErr(err)
-			for j := range info {
-				info[j].Parents[0] = subf[i]
-			}
 
-			if row.Subf.Valid {
-				err = json.Unmarshal([]byte(row.Subf.String), &innerSubf)
-				utils.CheckErr(err)
-			}
-
-			ress = append(ress, res{info, innerSubf})
-		}
-
-		for i := range ress {
-			subSubf = append(subSubf, ress[i].Subf...)
-			result = append(result, ress[i].Info...)
-		}
-
-		return recur(result, subSubf)
-	}
-
-	var result []*drive.File
-	var subf database.GdSubf
-	gd, exists, err := db.GDGet(fid)
+	_, exists, err := db.GDGet(fid)
 	utils.CheckErr(err)
 
 	if !exists {
 		return []*drive.File{}, err
 	}
 
-	err = json.Unmarshal([]byte(gd.Info.String), &result)
-	utils.CheckErr(err)
-
-	for i := range result {
-		result[i].Parents[0] = fid
+	rows, err := db.FilesGetTree(fid)
+	if err != nil {
+		return nil, err
 	}
-
-	if gd.Subf.Valid {
-		err = json.Unmarshal([]byte(gd.Subf.String), &subf)
-		utils.CheckErr(err)
+	result := make([]*drive.File, 0, len(rows))
+	for _, i := range rows {
+		result = append(result, i.ToFile())
 	}
 
-	if len(subf) == 0 {
-		return result, nil
+	missing, err := db.FilesMissingFolders(fid)
+	if err != nil {
+		return result, err
 	}
-
-	return recur(result, subf)
+	if missing > 0 {
+		logger.Debug("%d folders under %s are not cached", missing, fid)
+		return result, sql.ErrNoRows
+	}
+	return result, nil
 }
 
 func createFolder(ctx context.Context, name string, parent []string) (*drive.File, error) {
@@ -440,6 +459,13 @@ func createFolder(ctx context.Context, name string, parent []string) (*drive.Fil
 	return f, err
 }
 
+func listFields(withModifiedtime bool) []googleapi.Field {
+	if withModifiedtime {
+		return []googleapi.Field{"nextPageToken", "files(id,name,md5Checksum,mimeType,size,modifiedTime,parents)"}
+	}
+	return []googleapi.Field{"nextPageToken", "files(id,name,md5Checksum,mimeType,size,parents)"}
+}
+
 func lsFolder(ctx context.Context, fid string, notTeamdrive, withModifiedtime bool) ([]*drive.File, error) {
 	args := ListArgs{}
 
@@ -450,25 +476,23 @@ func lsFolder(ctx context.Context, fid string, notTeamdrive, withModifiedtime bo
 
 	args.SortOrder = "folder,name desc"
 	args.Query = fmt.Sprintf("'%s' in parents and trashed = false", fid)
-	args.Fields = []googleapi.Field{"nextPageToken", "files(id,name,md5Checksum,mimeType,size,parents)"}
-
-	if withModifiedtime {
-		args.Fields = []googleapi.Field{"nextPageToken", "files(id,name,md5Checksum,mimeType,size,modifiedTime,parents)"}
-	}
+	args.Fields = listFields(withModifiedtime)
 	files, err := fileListCall(ctx, args)
 
 	return files, err
 }
 
-func Count(fid, sort, outType, output string, update, notTeamdrive bool) error {
+func Count(ctx context.Context, fid, sort, outType, output string, update, notTeamdrive bool) error {
 	sort = strings.ToLower(sort)
 	outType = strings.ToLower(outType)
-	output = strings.ToLower(output)
 	var outStr string
-	var ctx = context.TODO()
 
-	if !update {
-		if outType != "" && sort != "" && output != "" {
+	// the changes are applied once, before both the cached tree is read and it is walked
+	commit := refreshCache(ctx, fid, notTeamdrive, update)
+
+	// the cached tree is walked again if its root is expired
+	if !update && !cacheExpired(fid) {
+		if outType == "" && sort == "" && output == "" {
 			record, _, err := db.GDGet(fid)
 			utils.CheckErr(err)
 
@@ -483,7 +507,7 @@ func Count(fid, sort, outType, output string, update, notTeamdrive bool) error {
 			logger.Panic("", err)
 		}
 		if len(info) > 0 {
-			outStr = summary.GetOutStr(info, outType, sort)
+			outStr = summary.GetOutStr(fid, info, outType, sort)
 			if output != "" {
 				err := ioutil.WriteFile(output, []byte(outStr), 0666)
 				utils.CheckErr(err)
@@ -491,17 +515,24 @@ func Count(fid, sort, outType, output string, update, notTeamdrive bool) error {
 			}
 		}
 	}
-	files, err := walkAndSave(ctx, fid, notTeamdrive, update, false)
-	utils.CheckErr(err)
-	out := summary.GetOutStr(files, outType, sort)
+	files, err := walkTree(ctx, fid, notTeamdrive, update, false, commit)
+	if err != nil {
+		return err
+	}
+	out := summary.GetOutStr(fid, files, outType, sort)
+	if output != "" {
+		err := ioutil.WriteFile(output, []byte(out), 0666)
+		utils.CheckErr(err)
+		return nil
+	}
 	fmt.Println(out)
 	return nil
 }
 
-func Copy(source, target, name string, minSize int64, update bool, notTeamdrive, dncr bool) (*drive.File, error) {
-	logger.Debugw("Copy operation started", "source", source, "name", name, "minSize", minSize, "update", update, "notTeamdrive", notTeamdrive, "dncr", dncr)
-	var task database.TaskDB
-	var ctx = context.TODO()
+// Copy copies the source into the target. If incremental is set, the files which already exist at the target
+// are skipped, they are matched either by name and size or by md5Checksum according to match.
+func Copy(ctx context.Context, source, target, name string, minSize int64, update bool, notTeamdrive, dncr, yes, incremental bool, match string) (*drive.File, error) {
+	logger.Debugw("Copy operation started", "source", source, "name", name, "minSize", minSize, "update", update, "notTeamdrive", notTeamdrive, "dncr", dncr, "yes", yes, "incremental", incremental, "match", match)
 
 	if target == "" {
 		target = config.DefaultTarget
@@ -520,43 +551,76 @@ func Copy(source, target, name string, minSize int64, update bool, notTeamdrive,
 	}
 	if file.Id != "" && file.MimeType != FolderType {
 		logger.Debug("Source is a file")
-		f, err := copyFile(ctx, source, target, 0)
+		f, err := copyFile(ctx, source, target, file.Size)
 		if err == nil {
 			return f, nil
 		}
 		logger.Error("", err)
 	}
 
-	task, exists, err := db.TaskGet(source, target)
-	// if exists && task.Status == "copying" {
-	// logger.Debug("", "This Task is already running. Force Quit")
-	// return nil, err
-	// }
+	release, err := lockTask(source, target, yes)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var index *destIndex
+	if incremental {
+		index = newDestIndex(match, notTeamdrive)
+	}
 
-	_, err = realCopy(ctx, source, target, name, int(minSize), update, dncr, notTeamdrive)
+	_, err = realCopy(ctx, source, target, name, int(minSize), update, dncr, notTeamdrive, yes, index)
 	if err != nil {
 		logger.Error("Error copying folder %s", err)
-		task, exists, err = db.TaskGet(source, target)
+		task, exists, err := db.TaskGet(source, target)
 		if err != nil {
 			logger.Error("", err)
 		}
 		if exists {
-			err := db.TaskStatusUpdate(task.ID, "error")
+			finishTask(ctx, task.ID, "error")
+		}
+	}
+	return nil, ctx.Err()
+}
+
+// finishTask sets the final status of the task, unless the operation is interrupted. The interrupted tasks
+// keep their mapping and copied records, so they can be continued on the next run.
+func finishTask(ctx context.Context, taskID int, status string) {
+	if ctx.Err() != nil {
+		status = "interrupted"
+		fmt.Printf("\nTask %d is interrupted, it can be continued with \"gdutils task resume %d\"\n", taskID, taskID)
+	}
+	logger.Info("Task %d is %s", taskID, status)
+	if err := db.TaskStatusUpdate(taskID, status); err != nil {
+		logger.Error("", err)
+	}
+}
+
+func realCopy(ctx context.Context, source, target, name string, minSize int, update, dncr, notTeamdrive, yes bool, index *destIndex) (database.CopiedDB, error) {
+	createRoot := func(name string) (*drive.File, error) {
+		if index != nil {
+			folder, err := index.folder(ctx, target, name)
 			if err != nil {
-				logger.Error("", err)
+				return nil, err
+			}
+			if folder != nil {
+				logger.Debug("Using the existing folder %s as root", folder.Id)
+				return folder, nil
 			}
 		}
+		folder, err := createFolder(ctx, name, []string{target})
+		if err == nil && index != nil {
+			index.created(folder.Id)
+		}
+		return folder, err
 	}
-	return nil, nil
-}
 
-func realCopy(ctx context.Context, source, target, name string, minSize int, update, dncr, notTeamdrive bool) (database.CopiedDB, error) {
 	getNewRoot := func() (*drive.File, error) {
 		if dncr {
 			return &drive.File{Id: target}, nil
 		}
 		if name != "" {
-			return createFolder(ctx, name, []string{target})
+			return createRoot(name)
 		}
 		file, err := getNameByID(ctx, source)
 		if err != nil {
@@ -565,7 +629,7 @@ func realCopy(ctx context.Context, source, target, name string, minSize int, upd
 		if file == "" {
 			logger.Panic("Unable to access the link, please check if the link is valid and SA has the appropriate permissions：https://drive.google.com/drive/folders/%s", source)
 		}
-		return createFolder(ctx, file, []string{target})
+		return createRoot(file)
 	}
 
 	logger.Debug("Checking source: %s - target:%s on TasksDB", source, target)
@@ -575,7 +639,7 @@ func realCopy(ctx context.Context, source, target, name string, minSize int, upd
 		newRoot, err := getNewRoot()
 		utils.CheckErr(err)
 		rootMapping := fmt.Sprintf("%s %s\n", source, newRoot.Id)
-		res, err := db.TaskInsert(source, target, "copying", rootMapping)
+		res, err := db.TaskInsert(source, target, "copying", rootMapping, minSize, index.matchMode())
 		if err != nil {
 			logger.Error("", err)
 		}
@@ -583,33 +647,19 @@ func realCopy(ctx context.Context, source, target, name string, minSize int, upd
 		if err != nil {
 			logger.Error("", err)
 		}
-		arr, err := walkAndSave(ctx, source, notTeamdrive, update, false)
-		utils.CheckErr(err)
-
-		files, folders := filterAll(arr, minSize)
-		logger.Debug("Number of folders to be copied - %d", len(folders))
-		logger.Debug("Number of files to be copied - %d", len(files))
-
-		mapping := CreateFolders(ctx, source, nil, folders, newRoot, int(lastInsertID))
-		copyFiles(ctx, files, mapping, newRoot, int(lastInsertID))
-		err = db.TaskStatusUpdate(int(lastInsertID), "finished")
-		if err != nil {
-			logger.Error("", err)
-		}
+		copyTree(ctx, int(lastInsertID), source, newRoot, minSize, update, notTeamdrive, index, nil, nil)
 
 		return database.CopiedDB{
 			TaskID: int(lastInsertID),
 			FileID: newRoot.Id,
 		}, nil
 	} else if err == nil {
-		copied, err := db.CopiedGet(task.ID)
-		if err != nil {
-			logger.Error("", err)
-		}
-
-		choice, _, err := prompter.PromptUserChoice.Run()
-		if err != nil {
-			logger.Error("", err)
+		choice := prompter.OptionContinue
+		if !yes {
+			choice, _, err = prompter.PromptUserChoice.Run()
+			if err != nil {
+				logger.Error("", err)
+			}
 		}
 
 		switch {
@@ -618,47 +668,10 @@ func realCopy(ctx context.Context, source, target, name string, minSize int, upd
 			return database.CopiedDB{}, nil
 		case choice == prompter.OptionContinue:
 			logger.Debug("", "Continue option selected")
-			var mapping [][]*drive.File
-			copiedIds := make(map[string]bool)
-			oldMappings := make(map[string]*drive.File)
-			for _, i := range copied {
-				copiedIds[i.FileID] = true
-			}
-			logger.Debug("", "Getting mapping from tasks db")
-			mappingArray := strings.Split(strings.Trim(task.Mapping, " "), "\n")
-			for _, i := range mappingArray {
-				var keh []*drive.File
-				maps := strings.Split(i, " ")
-				for _, j := range maps {
-					keh = append(keh, &drive.File{Id: j})
-				}
-				mapping = append(mapping, keh)
-			}
-
-			root := mapping[0][1]
-			for _, i := range mapping {
-				oldMappings[i[0].Id] = i[1]
-			}
-			logger.Debug("%s - %s", "updating db", task.ID)
-			err := db.TaskStatusUpdate(task.ID, "copying")
+			root, err := resumeTask(ctx, task, minSize, update, notTeamdrive, index)
 			if err != nil {
-				logger.Error("", err)
+				return database.CopiedDB{}, err
 			}
-			arr, err := walkAndSave(ctx, source, notTeamdrive, update, false)
-			if err != nil {
-				logger.Error("", err)
-			}
-			files, folders := filterAll(arr, minSize)
-			logger.Debug("No of files: %d", len(files))
-			logger.Debug("No of folders: %d", len(folders))
-
-			allMapping := CreateFolders(ctx, source, oldMappings, folders, root, task.ID)
-			copyFiles(ctx, files, allMapping, root, task.ID)
-			err = db.TaskStatusUpdate(task.ID, "finished")
-			if err != nil {
-				logger.Error("", err)
-			}
-
 			return database.CopiedDB{
 				TaskID: task.ID,
 				FileID: root.Id,
@@ -668,7 +681,7 @@ func realCopy(ctx context.Context, source, target, name string, minSize int, upd
 			newRoot, err := getNewRoot()
 			utils.CheckErr(err)
 			rootMapping := fmt.Sprintf("%s %s\n", source, newRoot.Id)
-			err = db.TaskUpdate(task.ID, "copying", rootMapping)
+			err = db.TaskUpdate(task.ID, "copying", rootMapping, minSize, index.matchMode())
 			if err != nil {
 				logger.Error("", err)
 			}
@@ -676,17 +689,11 @@ func realCopy(ctx context.Context, source, target, name string, minSize int, upd
 			if err != nil {
 				logger.Error("", err)
 			}
-			arr, err := walkAndSave(ctx, source, notTeamdrive, update, false)
-			utils.CheckErr(err)
-			files, folders := filterAll(arr, minSize)
-			fmt.Println("Number of folders to be copied", len(folders))
-			fmt.Println("Number of files to be copied", len(files))
-			mapping := CreateFolders(ctx, source, nil, folders, newRoot, task.ID)
-			copyFiles(ctx, files, mapping, newRoot, task.ID)
-			err = db.TaskStatusUpdate(task.ID, "finished")
+			err = db.FailedDelete(task.ID)
 			if err != nil {
 				logger.Error("", err)
 			}
+			copyTree(ctx, task.ID, source, newRoot, minSize, update, notTeamdrive, index, nil, nil)
 			return database.CopiedDB{
 				TaskID: task.ID,
 				FileID: newRoot.Id,
@@ -700,20 +707,20 @@ func realCopy(ctx context.Context, source, target, name string, minSize int, upd
 	return database.CopiedDB{}, err
 }
 
-func copyFiles(ctx context.Context, files []*drive.File, mapping map[string]*drive.File, root *drive.File, taskID int) {
+func copyFiles(ctx context.Context, files []*drive.File, mapping map[string]*drive.File, root *drive.File, taskID int, index *destIndex) {
 	var wg sync.WaitGroup
-	var count = new(counter.Counter)
 	var pendingCount = new(counter.Counter)
 	ctx, cancel := context.WithCancel(ctx)
-	limiter := ratelimit.New(100)
 	defer cancel()
 
 	if len(files) == 0 {
 		return
 	}
+
+	copier := newFileCopier(taskID, index, ratelimit.New(100))
 	fmt.Printf("\nStarted copying files, total：%d\n", len(files))
 	logger.Info("Started copying files, total：%d", len(files))
-	go status.PrintStatus(ctx, pendingCount, count, status.StatusCopy)
+	go status.PrintStatus(ctx, pendingCount, copier.copied, status.StatusCopy)
 
 	pendingCount.Set(int32(len(files)))
 	for _, item := range files {
@@ -725,141 +732,40 @@ func copyFiles(ctx context.Context, files []*drive.File, mapping map[string]*dri
 				sema.Signal()
 			}()
 
-			var target *drive.File
-			if innerItem.Id == "" {
-				return
-			}
-
-			if len(innerItem.Parents) > 0 && mapping[innerItem.Parents[0]].Id != "" {
-				target = mapping[innerItem.Parents[0]]
-			} else {
-				target = root
-			}
-
-			limiter.Take()
-			newfile, err := copyFile(ctx, innerItem.Id, target.Id, taskID)
-			pendingCount.Dec()
-			// todo buraya db'ye hata olarak ekleme ozelligi konulacak
-			if err != nil {
-				logger.Error("FAA %s", err)
-				return
-			}
-
-			// utils.CheckErr(err)
-
-			if newfile.Id != "" {
-				count.Inc()
-				err := db.CopiedInsert(taskID, innerItem.Id)
-				if err != nil {
-					logger.Error("", err)
+			// the file stays failed if its folder is not at the destination, it is not copied somewhere else
+			target := root
+			if len(innerItem.Parents) > 0 {
+				parent, ok := mapping[innerItem.Parents[0]]
+				if !ok || parent.Id == "" {
+					logger.Error("No destination folder found for the parent %s of %s", innerItem.Parents[0], innerItem.Id)
+					copier.fail(innerItem, fmt.Errorf("no destination folder found for the parent %s", innerItem.Parents[0]))
+					pendingCount.Dec()
+					return
 				}
+				target = parent
 			}
+			copier.copy(ctx, innerItem, target.Id)
+			pendingCount.Dec()
 		}(item)
 	}
 	wg.Wait()
 	cancel()
+	fmt.Println()
+	copier.report()
 }
 
-func copyFile(ctx context.Context, id, parent string, taskID int) (*drive.File, error) {
+// copyFile copies the file into parent, and adds its size to the bytes copied today by the Sa which copied it.
+func copyFile(ctx context.Context, id, parent string, size int64) (*drive.File, error) {
 	args := ListArgs{supportsAllDrives: true}
-	file, err := fileCopyCall(ctx, id, parent, args)
+	file, email, err := fileCopyCall(ctx, id, parent, args)
 	if err != nil {
-		if taskID != 0 {
-			err := db.TaskUpdate(taskID, "error", "")
-			logger.Error("", err)
-		}
 		return nil, err
 	}
-	return file, err
-}
-
-func CreateFolders(ctx context.Context, source string, oldMapping map[string]*drive.File, folders []*drive.File, root *drive.File, taskId int) map[string]*drive.File {
-	logger.Debugw("Creating folders", "source", source, "oldMapping", oldMapping, "folders", folders)
-	var wg sync.WaitGroup
-	var mut = new(sync.Mutex)
-	var count = new(counter.Counter)
-	var pendingCount = new(counter.Counter)
-	var sameLevels []*drive.File
-	limiter := ratelimit.New(100)
-	var sameLevelsMissed []*drive.File
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-
-	mapping := make(map[string]*drive.File)
-	if len(oldMapping) > 0 {
-		mapping = oldMapping
-	}
-	mapping[source] = root
-	if len(folders) == 0 {
-		return mapping
-	}
-
-	missedFolders := make([]*drive.File, 0)
-	for _, i := range folders {
-		if v, ok := mapping[i.Id]; !ok {
-			missedFolders = append(missedFolders, v)
-		}
-	}
-
-	fmt.Printf("Start creating folders, total: %d\n", len(missedFolders))
-
-	go status.PrintStatus(ctx, pendingCount, count, status.StatusCreateFolder)
-
-	for _, i := range folders {
-		if i.Parents[0] == folders[0].Parents[0] {
-			sameLevels = append(sameLevels, i)
-		}
-	}
-
-	pendingCount.Set(int32(len(folders)))
-
-	for len(sameLevels) > 0 {
-		var lolo []*drive.File
-		for _, i := range sameLevels {
-			if _, ok := mapping[i.Id]; !ok {
-				lolo = append(lolo, i)
-			}
-		}
-		sameLevelsMissed = lolo
-		for _, item := range sameLevelsMissed {
-			wg.Add(1)
-			go func(innerItem *drive.File) {
-				sema.Wait()
-				defer wg.Done()
-				var target string
-				mut.Lock()
-				if _, ok := mapping[innerItem.Parents[0]]; !ok {
-					target = root.Id
-				} else {
-					target = mapping[innerItem.Parents[0]].Id
-				}
-				mut.Unlock()
-				limiter.Take()
-				newFolder, err := createFolder(ctx, innerItem.Name, []string{target})
-				utils.CheckErr(err)
-				count.Inc()
-				pendingCount.Dec()
-				mut.Lock()
-				mapping[innerItem.Id] = newFolder
-				mut.Unlock()
-				mappingRecord := fmt.Sprintf("%s %s\n", innerItem.Id, newFolder.Id)
-				err = db.TaskAddMapping(taskId, mappingRecord)
-				if err != nil {
-					logger.Error("", err)
-				}
-				sema.Signal()
-			}(item)
-		}
-		wg.Wait()
-		var k []*drive.File
-		for _, i := range sameLevels {
-			for _, j := range folders {
-				if i.Id == j.Parents[0] {
-					k = append(k, j)
-				}
-			}
+	if email != "" && size > 0 {
+		SaConfigs.AddUsage(email, size)
+		if err := db.SaUsageAdd(email, auth.UsageDay(time.Now()), size); err != nil {
+			logger.Error("", err)
 		}
-		sameLevels = k
 	}
-	return mapping
+	return file, err
 }
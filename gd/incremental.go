@@ -0,0 +1,117 @@
+package gd
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/api/drive/v3"
+
+	"github.com/xybydy/gdutils/logger"
+)
+
+const (
+	MatchName = "name"
+	MatchMd5  = "md5"
+)
+
+// destIndex lists the destination folders lazily, once per folder, so the files which are already
+// at the destination can be skipped on incremental copies.
+type destIndex struct {
+	mu           sync.Mutex
+	listings     map[string]*destListing
+	match        string
+	notTeamdrive bool
+}
+
+type destListing struct {
+	once  sync.Once
+	files []*drive.File
+	err   error
+}
+
+func newDestIndex(match string, notTeamdrive bool) *destIndex {
+	if match != MatchMd5 {
+		match = MatchName
+	}
+	return &destIndex{
+		listings:     make(map[string]*destListing),
+		match:        match,
+		notTeamdrive: notTeamdrive,
+	}
+}
+
+// matchMode returns how the files are matched, it is empty if the copy is not incremental.
+func (d *destIndex) matchMode() string {
+	if d == nil {
+		return ""
+	}
+	return d.match
+}
+
+func (d *destIndex) listing(parent string) *destListing {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	l, ok := d.listings[parent]
+	if !ok {
+		l = new(destListing)
+		d.listings[parent] = l
+	}
+	return l
+}
+
+func (d *destIndex) children(ctx context.Context, parent string) ([]*drive.File, error) {
+	l := d.listing(parent)
+	l.once.Do(func() {
+		logger.Debug("Listing destination folder %s", parent)
+		l.files, l.err = lsFolder(ctx, parent, d.notTeamdrive, false)
+	})
+	return l.files, l.err
+}
+
+// created marks the folder as a new and empty one, so it is never listed.
+func (d *destIndex) created(id string) {
+	d.listing(id).once.Do(func() {})
+}
+
+// folder returns the folder with the given name under parent, if there is any.
+func (d *destIndex) folder(ctx context.Context, parent, name string) (*drive.File, error) {
+	files, err := d.children(ctx, parent)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range filterFolders(files) {
+		if i.Name == name {
+			return i, nil
+		}
+	}
+	return nil, nil
+}
+
+// compare checks whether the file exists under parent.
+func (d *destIndex) compare(ctx context.Context, file *drive.File, parent string) (bool, error) {
+	files, err := d.children(ctx, parent)
+	if err != nil {
+		return false, err
+	}
+	return matchExisting(file, filterFiles(files, 0), d.match), nil
+}
+
+// matchExisting compares the file with the files at the destination either by name and size or by md5Checksum.
+// Files having the same name but different content are left at the destination, the changed file is copied
+// next to them.
+func matchExisting(file *drive.File, existing []*drive.File, match string) bool {
+	if match == MatchMd5 && file.Md5Checksum != "" {
+		for _, i := range existing {
+			if i.Md5Checksum == file.Md5Checksum && i.Size == file.Size {
+				return true
+			}
+		}
+	}
+
+	for _, i := range existing {
+		if i.Name == file.Name && i.Size == file.Size && (match != MatchMd5 || i.Md5Checksum == file.Md5Checksum) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package gd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/api/drive/v3"
+)
+
+type IncrementalSuite struct {
+	suite.Suite
+	existing []*drive.File
+}
+
+func (suite *IncrementalSuite) SetupTest() {
+	suite.existing = []*drive.File{
+		{Id: "1", Name: "a.txt", Size: 10, Md5Checksum: "aaa"},
+		{Id: "2", Name: "b.txt", Size: 20, Md5Checksum: "bbb"},
+		{Id: "3", Name: "doc", Size: 0},
+	}
+}
+
+func (suite *IncrementalSuite) TestMatchExisting() {
+	tests := []struct {
+		name  string
+		give  *drive.File
+		match string
+		want  bool
+	}{
+		{"same name and size", &drive.File{Name: "a.txt", Size: 10, Md5Checksum: "zzz"}, MatchName, true},
+		{"changed size", &drive.File{Name: "a.txt", Size: 11, Md5Checksum: "aaa"}, MatchName, false},
+		{"new file", &drive.File{Name: "c.txt", Size: 10, Md5Checksum: "aaa"}, MatchName, false},
+		{"google doc", &drive.File{Name: "doc"}, MatchName, true},
+		{"same md5 other name", &drive.File{Name: "c.txt", Size: 20, Md5Checksum: "bbb"}, MatchMd5, true},
+		{"changed md5", &drive.File{Name: "a.txt", Size: 10, Md5Checksum: "ccc"}, MatchMd5, false},
+		{"google doc by md5", &drive.File{Name: "doc"}, MatchMd5, true},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			suite.Equal(tt.want, matchExisting(tt.give, suite.existing, tt.match))
+		})
+	}
+}
+
+func TestIncrementalSuite(t *testing.T) {
+	suite.Run(t, new(IncrementalSuite))
+}
@@ -0,0 +1,90 @@
+package gd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/xybydy/gdutils/database"
+	"github.com/xybydy/gdutils/logger"
+	"github.com/xybydy/gdutils/prompter"
+)
+
+const (
+	leaseHeartbeat = 30 * time.Second
+	// leaseTimeout is how long a lease is kept without a heartbeat, before its owner is considered dead.
+	leaseTimeout = 2 * time.Minute
+)
+
+var ErrTaskLocked = errors.New("task is locked by another process")
+
+// lockTask takes the lease of the source and target pair, and keeps it alive until the returned release func
+// is called. The lease of a process which has stopped sending heartbeats is taken over after confirmation,
+// or without asking if yes is set.
+func lockTask(source, target string, yes bool) (func(), error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	owner := database.LeaseDB{Source: source, Target: target, Pid: os.Getpid(), Hostname: hostname}
+	staleBefore := time.Now().Add(-leaseTimeout).Unix()
+
+	holder, acquired, err := db.LeaseAcquire(owner, staleBefore, false)
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		lastSeen := time.Since(time.Unix(holder.Heartbeat, 0)).Round(time.Second)
+		if holder.Heartbeat >= staleBefore {
+			return nil, fmt.Errorf("%w: %s, last heartbeat %s ago", ErrTaskLocked, holder.Owner(), lastSeen)
+		}
+
+		takeover := yes
+		if !yes {
+			takeover, err = prompter.PromptTakeover(fmt.Sprintf("%s (last heartbeat %s ago)", holder.Owner(), lastSeen))
+			if err != nil {
+				return nil, err
+			}
+		}
+		if !takeover {
+			return nil, fmt.Errorf("%w: %s", ErrTaskLocked, holder.Owner())
+		}
+
+		logger.Info("Taking over the lease of %s, last heartbeat %s ago", holder.Owner(), lastSeen)
+		holder, acquired, err = db.LeaseAcquire(owner, staleBefore, true)
+		if err != nil {
+			return nil, err
+		}
+		if !acquired {
+			return nil, fmt.Errorf("%w: %s", ErrTaskLocked, holder.Owner())
+		}
+	}
+
+	// The heartbeat does not stop on interrupts, the lease is held until the running requests are drained.
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(leaseHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				held, err := db.LeaseHeartbeat(owner)
+				if err != nil {
+					logger.Error("", err)
+				} else if !held {
+					logger.Error("The lease of %s -> %s is taken over by another process", source, target)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		if err := db.LeaseRelease(owner); err != nil {
+			logger.Error("", err)
+		}
+	}, nil
+}
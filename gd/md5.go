@@ -0,0 +1,47 @@
+package gd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/xybydy/gdutils/logger"
+	"github.com/xybydy/gdutils/utils"
+)
+
+// Md5 records the md5 hashes of the files in the given folder which are not smaller than size.
+func Md5(ctx context.Context, fid, size string, update, notTeamdrive bool) error {
+	logger.Debugw("Md5 operation started", "fid", fid, "size", size, "update", update, "notTeamdrive", notTeamdrive)
+
+	if !validateFid(fid) {
+		return errors.New("invalid folder id")
+	}
+
+	minSize, err := utils.ParseSize(size)
+	if err != nil {
+		return err
+	}
+
+	count, err := saveMd5(ctx, fid, minSize, notTeamdrive, update)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nNew md5 records: %d\n", count)
+	return nil
+}
+
+// Md5Lookup prints the IDs of the files recorded with the given md5.
+func Md5Lookup(md5 string) error {
+	ids, err := getGIDByMd5(md5)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		fmt.Printf("No file found with md5: %s\n", md5)
+		return nil
+	}
+	for _, i := range ids {
+		fmt.Println(i)
+	}
+	return nil
+}
@@ -0,0 +1,397 @@
+package gd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.uber.org/ratelimit"
+	"google.golang.org/api/drive/v3"
+
+	"github.com/xybydy/gdutils/config"
+	"github.com/xybydy/gdutils/counter"
+	"github.com/xybydy/gdutils/logger"
+	"github.com/xybydy/gdutils/status"
+	"github.com/xybydy/gdutils/utils"
+)
+
+// maxQueuedFiles is the number of files waiting to be copied after which no more folders are listed,
+// so the memory use does not grow with the size of the tree.
+const maxQueuedFiles = 1000
+
+const (
+	jobList = iota
+	jobMkdir
+	jobCopy
+)
+
+// pipelineJob is a source item along with its destination. For jobList and jobCopy dest is the destination
+// folder of the item, for jobMkdir it is the folder the new folder is created in.
+type pipelineJob struct {
+	kind int
+	item *drive.File
+	dest string
+}
+
+// copyPipeline copies a folder tree in one pass. Each folder is listed as soon as its destination folder
+// exists, and its files are copied while the rest of the tree is still being listed. All the requests are
+// made by a fixed number of workers.
+type copyPipeline struct {
+	taskID       int
+	minSize      int
+	update       bool
+	notTeamdrive bool
+	index        *destIndex
+	copier       *fileCopier
+	limiter      ratelimit.Limiter
+
+	// mapping is the destination folders keyed by the source folder ids, copied is the files which are
+	// copied by the previous runs of the task.
+	mapping map[string]*drive.File
+	copied  map[string]bool
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	files   []pipelineJob
+	folders []pipelineJob
+	running int
+
+	listed  *counter.Counter
+	pending *counter.Counter
+	errors  *counter.Counter
+}
+
+func newCopyPipeline(taskID, minSize int, update, notTeamdrive bool, index *destIndex, mapping map[string]*drive.File, copied map[string]bool) *copyPipeline {
+	limiter := ratelimit.New(100)
+	if mapping == nil {
+		mapping = make(map[string]*drive.File)
+	}
+	p := &copyPipeline{
+		taskID:       taskID,
+		minSize:      minSize,
+		update:       update,
+		notTeamdrive: notTeamdrive,
+		index:        index,
+		copier:       newFileCopier(taskID, index, limiter),
+		limiter:      limiter,
+		mapping:      mapping,
+		copied:       copied,
+		listed:       new(counter.Counter),
+		pending:      new(counter.Counter),
+		errors:       new(counter.Counter),
+	}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// copyTree copies the source folder into root for the task, and sets the final status of the task.
+func copyTree(ctx context.Context, taskID int, source string, root *drive.File, minSize int, update, notTeamdrive bool, index *destIndex, mapping map[string]*drive.File, copied map[string]bool) {
+	p := newCopyPipeline(taskID, minSize, update, notTeamdrive, index, mapping, copied)
+	if p.run(ctx, source, root) {
+		finishTask(ctx, taskID, "finished")
+	} else {
+		finishTask(ctx, taskID, "error")
+	}
+}
+
+// run copies the source folder into root, and reports whether the whole tree could be listed.
+func (p *copyPipeline) run(ctx context.Context, source string, root *drive.File) bool {
+	logger.Debug("Copy pipeline started for %s -> %s", source, root.Id)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	commit := refreshCache(ctx, source, p.notTeamdrive, p.update)
+	if p.update {
+		if err := db.GDUpdateSummary(source, ""); err != nil {
+			logger.Error("", err)
+		}
+	}
+	// A shared drive is listed at once, then its folders are read from the cache.
+	if useDriveListing(ctx, source, p.notTeamdrive, p.update) {
+		if _, err := walkDrive(ctx, source, false); err != nil {
+			logger.Error("", err)
+		} else {
+			p.update = false
+		}
+	}
+
+	// wakes the idle workers up, so they can see that the copy is interrupted
+	go func() {
+		<-ctx.Done()
+		p.mu.Lock()
+		p.cond.Broadcast()
+		p.mu.Unlock()
+	}()
+	statusDone := make(chan struct{})
+	go func() {
+		status.PrintCopyStatus(ctx, p.listed, p.copier.copied, p.pending)
+		close(statusDone)
+	}()
+
+	p.mapping[source] = root
+	p.push(pipelineJob{kind: jobList, item: &drive.File{Id: source}, dest: root.Id})
+
+	var wg sync.WaitGroup
+	for i := 0; i < config.ParallelLimit; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				job, ok := p.next(ctx)
+				if !ok {
+					return
+				}
+				p.do(ctx, job)
+				p.done()
+			}
+		}()
+	}
+	wg.Wait()
+	interrupted := ctx.Err() != nil
+	cancel()
+	<-statusDone
+
+	fmt.Println()
+	p.copier.report()
+	if p.errors.Get() > 0 {
+		fmt.Printf("Folders failed: %d, the files in them are not copied\n", p.errors.Get())
+		logger.Error("Folders failed: %d", p.errors.Get())
+	}
+	// the changes are tracked from now on only if the whole tree is cached
+	if p.errors.Get() == 0 && !interrupted {
+		commit()
+	}
+	return p.errors.Get() == 0
+}
+
+func (p *copyPipeline) push(jobs ...pipelineJob) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, i := range jobs {
+		if i.kind == jobCopy {
+			p.files = append(p.files, i)
+			p.pending.Inc()
+		} else {
+			p.folders = append(p.folders, i)
+		}
+	}
+	p.cond.Broadcast()
+}
+
+// next waits for a job. The folders are preferred until enough files are queued, and they are taken in
+// the last in first out order, so the tree is walked depth first and the queue stays small.
+func (p *copyPipeline) next(ctx context.Context) (pipelineJob, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		var job pipelineJob
+		switch {
+		case ctx.Err() != nil:
+			return job, false
+		case len(p.folders) > 0 && len(p.files) < maxQueuedFiles:
+			job = p.folders[len(p.folders)-1]
+			p.folders = p.folders[:len(p.folders)-1]
+		case len(p.files) > 0:
+			job = p.files[0]
+			p.files[0] = pipelineJob{}
+			p.files = p.files[1:]
+		case p.running == 0:
+			return job, false
+		default:
+			p.cond.Wait()
+			continue
+		}
+		p.running++
+		return job, true
+	}
+}
+
+func (p *copyPipeline) done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.running--
+	p.cond.Broadcast()
+}
+
+func (p *copyPipeline) do(ctx context.Context, job pipelineJob) {
+	switch job.kind {
+	case jobList:
+		p.list(ctx, job)
+	case jobMkdir:
+		p.mkdir(ctx, job)
+	case jobCopy:
+		p.copier.copy(ctx, job.item, job.dest)
+		p.pending.Dec()
+	}
+}
+
+func (p *copyPipeline) list(ctx context.Context, job pipelineJob) {
+	children, err := listChildren(ctx, job.item.Id, p.notTeamdrive, p.update, false, p.limiter)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Error("Unable to list %s: %s", job.item.Id, err)
+			p.errors.Inc()
+		}
+		return
+	}
+	p.listed.Inc()
+
+	files, folders := filterAll(children, p.minSize)
+	jobs := make([]pipelineJob, 0, len(children))
+	for _, i := range folders {
+		p.mu.Lock()
+		dest, ok := p.mapping[i.Id]
+		p.mu.Unlock()
+		if ok {
+			jobs = append(jobs, pipelineJob{kind: jobList, item: i, dest: dest.Id})
+		} else {
+			jobs = append(jobs, pipelineJob{kind: jobMkdir, item: i, dest: job.dest})
+		}
+	}
+	for _, i := range files {
+		if !p.copied[i.Id] {
+			jobs = append(jobs, pipelineJob{kind: jobCopy, item: i, dest: job.dest})
+		}
+	}
+	p.push(jobs...)
+}
+
+func (p *copyPipeline) mkdir(ctx context.Context, job pipelineJob) {
+	folder, err := makeFolder(ctx, job.item.Name, job.dest, p.index, p.limiter)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Error("Unable to create the folder %s: %s", job.item.Name, err)
+			p.errors.Inc()
+		}
+		return
+	}
+
+	p.mu.Lock()
+	p.mapping[job.item.Id] = folder
+	p.mu.Unlock()
+	if err := db.TaskAddMapping(p.taskID, fmt.Sprintf("%s %s\n", job.item.Id, folder.Id)); err != nil {
+		logger.Error("", err)
+	}
+	p.push(pipelineJob{kind: jobList, item: job.item, dest: folder.Id})
+}
+
+// makeFolder creates the folder under parent, or returns the existing one with the same name on
+// incremental copies.
+func makeFolder(ctx context.Context, name, parent string, index *destIndex, limiter ratelimit.Limiter) (*drive.File, error) {
+	if index != nil {
+		folder, err := index.folder(ctx, parent, name)
+		if err != nil || folder != nil {
+			return folder, err
+		}
+	}
+	limiter.Take()
+	folder, err := createFolder(ctx, name, []string{parent})
+	if err != nil {
+		return nil, err
+	}
+	if index != nil {
+		index.created(folder.Id)
+	}
+	return folder, nil
+}
+
+// fileCopier copies the files of a task, and keeps the copied and failed records of the task.
+type fileCopier struct {
+	taskID  int
+	index   *destIndex
+	limiter ratelimit.Limiter
+	// the files failed in the previous runs of the task, their records are removed once they are copied
+	failedBefore map[string]bool
+
+	copied  *counter.Counter
+	skipped *counter.Counter
+	failed  *counter.Counter
+}
+
+func newFileCopier(taskID int, index *destIndex, limiter ratelimit.Limiter) *fileCopier {
+	failedBefore := make(map[string]bool)
+	failed, err := db.FailedGet(taskID)
+	if err != nil {
+		logger.Error("", err)
+	}
+	for _, i := range failed {
+		failedBefore[i.FileID] = true
+	}
+	return &fileCopier{
+		taskID:       taskID,
+		index:        index,
+		limiter:      limiter,
+		failedBefore: failedBefore,
+		copied:       new(counter.Counter),
+		skipped:      new(counter.Counter),
+		failed:       new(counter.Counter),
+	}
+}
+
+// copy copies the file into the target folder. On incremental copies the file is skipped if it is already
+// at the target.
+func (c *fileCopier) copy(ctx context.Context, item *drive.File, target string) {
+	if item.Id == "" || ctx.Err() != nil {
+		return
+	}
+
+	if c.index != nil {
+		exists, err := c.index.compare(ctx, item, target)
+		if err != nil && ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Error("Unable to list the destination %s for %s: %s", target, item.Id, err)
+			c.fail(item, err)
+			return
+		}
+		if exists {
+			c.skipped.Inc()
+			return
+		}
+	}
+
+	c.limiter.Take()
+	newfile, err := copyFile(ctx, item.Id, target, item.Size)
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		logger.Error("FAA %s", err)
+		c.fail(item, err)
+		return
+	}
+	if newfile.Id == "" {
+		return
+	}
+
+	c.copied.Inc()
+	if err := db.CopiedInsert(c.taskID, item.Id); err != nil {
+		logger.Error("", err)
+	}
+	if c.failedBefore[item.Id] {
+		if err := db.FailedDeleteFile(c.taskID, item.Id); err != nil {
+			logger.Error("", err)
+		}
+	}
+}
+
+// fail records the file as failed, so that it is copied again by retry-failed.
+func (c *fileCopier) fail(item *drive.File, err error) {
+	c.failed.Inc()
+	if err := db.FailedInsert(c.taskID, item.Id, utils.RequestErrorType(err), err.Error()); err != nil {
+		logger.Error("", err)
+	}
+}
+
+func (c *fileCopier) report() {
+	logger.Info("Files copied: %d", c.copied.Get())
+	if c.index != nil {
+		fmt.Printf("Files already at the destination: %d\n", c.skipped.Get())
+		logger.Info("Files already at the destination: %d", c.skipped.Get())
+	}
+	if c.failed.Get() > 0 {
+		fmt.Printf("Files failed: %d, run \"gdutils task retry-failed %d\" to copy them again\n", c.failed.Get(), c.taskID)
+		logger.Info("Files failed: %d", c.failed.Get())
+	}
+}
@@ -0,0 +1,112 @@
+package gd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+
+	"github.com/xybydy/gdutils/database"
+	"github.com/xybydy/gdutils/logger"
+)
+
+// useDriveListing reports whether the folder is the root of a shared drive which is going to be listed
+// online, so that the whole drive can be listed at once instead of folder by folder.
+func useDriveListing(ctx context.Context, fid string, notTeamdrive, update bool) bool {
+	if notTeamdrive || fid == "root" {
+		return false
+	}
+	if !update {
+		exists, err := db.GDExist(fid)
+		if err != nil || exists && !cacheExpired(fid) {
+			return false
+		}
+	}
+
+	root, err := isDriveRoot(ctx, fid)
+	if err != nil {
+		logger.Error("", err)
+		return false
+	}
+	return root
+}
+
+func isDriveRoot(ctx context.Context, fid string) (bool, error) {
+	driveID, err := driveOf(ctx, fid)
+	return driveID != "" && driveID == fid, err
+}
+
+// driveOf returns the id of the shared drive the item is in, which is empty for the items in My Drive.
+func driveOf(ctx context.Context, fid string) (string, error) {
+	args := ListArgs{supportsAllDrives: true}
+	args.Fields = []googleapi.Field{"id", "driveId"}
+
+	f, err := fileGetCall(ctx, fid, args)
+	if err != nil {
+		return "", err
+	}
+	return f.DriveId, nil
+}
+
+// lsDrive lists all the items in the shared drive, in a single paged listing.
+func lsDrive(ctx context.Context, driveID string, withModifiedtime bool) ([]*drive.File, error) {
+	args := ListArgs{
+		includeItemsFromAllDrives: true,
+		supportsAllDrives:         true,
+		driveID:                   driveID,
+	}
+	args.Query = "trashed = false"
+	args.Fields = listFields(withModifiedtime)
+	return fileListCall(ctx, args)
+}
+
+// walkDrive lists the whole shared drive, rebuilds the folder hierarchy from the parents of the items and
+// caches all the folders at once.
+func walkDrive(ctx context.Context, driveID string, withModified bool) ([]*drive.File, error) {
+	fmt.Printf("Listing the shared drive %s\n", driveID)
+	logger.Info("Listing the shared drive %s", driveID)
+
+	items, err := lsDrive(ctx, driveID, withModified)
+	if err != nil {
+		return nil, err
+	}
+	folders, result := driveTree(driveID, items, time.Now().Unix())
+	fmt.Printf("Folders: %d, Items: %d\n", len(folders), len(result))
+
+	if err := db.FilesReplaceFolders(folders); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// driveTree groups the items by their parents, and returns the listings of the folders under the root along
+// with the items under it. The items which cannot be reached from the root are left out.
+func driveTree(root string, items []*drive.File, now int64) ([]database.CachedFolder, []*drive.File) {
+	children := make(map[string][]*drive.File)
+	for _, i := range items {
+		if len(i.Parents) > 0 {
+			children[i.Parents[0]] = append(children[i.Parents[0]], i)
+		}
+	}
+
+	var folders []database.CachedFolder
+	var result []*drive.File
+	visited := map[string]bool{root: true}
+	queue := []string{root}
+	for len(queue) > 0 {
+		fid := queue[0]
+		queue = queue[1:]
+
+		folders = append(folders, newCachedFolder(fid, children[fid], now))
+		result = append(result, children[fid]...)
+		for _, i := range children[fid] {
+			if i.MimeType == FolderType && !visited[i.Id] {
+				visited[i.Id] = true
+				queue = append(queue, i.Id)
+			}
+		}
+	}
+	return folders, result
+}
@@ -0,0 +1,356 @@
+package gd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/drive/v3"
+
+	"github.com/xybydy/gdutils/logger"
+	"github.com/xybydy/gdutils/prompter"
+	"github.com/xybydy/gdutils/status"
+)
+
+// ErrSyncIncomplete is returned when some of the operations of the sync have failed.
+var ErrSyncIncomplete = errors.New("sync is not complete")
+
+type syncItem struct {
+	Path  string
+	File  *drive.File
+	Stale []*drive.File
+}
+
+// syncPlan holds the operations making the destination identical to the source.
+type syncPlan struct {
+	Mkdirs  []syncItem
+	Copies  []syncItem
+	Updates []syncItem
+	Trashes []syncItem
+
+	// source folder id to destination folder id, for the folders already at the destination
+	mapping map[string]string
+}
+
+func (p syncPlan) IsEmpty() bool {
+	return len(p.Mkdirs) == 0 && len(p.Copies) == 0 && len(p.Updates) == 0 && len(p.Trashes) == 0
+}
+
+func (p syncPlan) Summary() string {
+	return fmt.Sprintf("Folders to create: %d, Files to copy: %d, Files to update: %d, Items to trash: %d",
+		len(p.Mkdirs), len(p.Copies), len(p.Updates), len(p.Trashes))
+}
+
+func (p syncPlan) String() string {
+	var b strings.Builder
+	for _, i := range p.Mkdirs {
+		fmt.Fprintf(&b, "mkdir  %s\n", i.Path)
+	}
+	for _, i := range p.Copies {
+		fmt.Fprintf(&b, "copy   %s\n", i.Path)
+	}
+	for _, i := range p.Updates {
+		fmt.Fprintf(&b, "update %s\n", i.Path)
+	}
+	for _, i := range p.Trashes {
+		fmt.Fprintf(&b, "trash  %s\n", i.Path)
+	}
+	b.WriteString(p.Summary())
+	b.WriteString("\n")
+	return b.String()
+}
+
+// Sync makes the destination identical to the source. Missing and changed files are copied, the items
+// which no longer exist in the source are trashed. The destination is locked like the copies into it.
+func Sync(ctx context.Context, source, dest string, notTeamdrive, dryRun, yes bool) error {
+	logger.Debugw("Sync operation started", "source", source, "dest", dest, "notTeamdrive", notTeamdrive, "dryRun", dryRun, "yes", yes)
+
+	if !validateFid(source) || !validateFid(dest) {
+		return errors.New("invalid folder id")
+	}
+
+	if !dryRun {
+		release, err := lockTask(source, dest, yes)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	// Both trees are always listed online, the plan must not be built on a stale cache.
+	srcArr, err := walkAndSave(ctx, source, notTeamdrive, true, false)
+	if err != nil {
+		return err
+	}
+	dstArr, err := walkAndSave(ctx, dest, notTeamdrive, true, false)
+	if err != nil {
+		return err
+	}
+
+	plan := makeSyncPlan(source, dest, srcArr, dstArr)
+	if dryRun {
+		fmt.Print("\n", plan.String())
+		return nil
+	}
+	fmt.Printf("\n%s\n", plan.Summary())
+	logger.Info("Sync plan: %s", plan.Summary())
+	if plan.IsEmpty() {
+		return nil
+	}
+
+	if len(plan.Trashes) > 0 && !yes {
+		confirmed, err := prompter.PromptTrash(len(plan.Trashes))
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			logger.Debug("", "Sync is not confirmed")
+			return nil
+		}
+	}
+
+	changed, failed := executeSyncPlan(ctx, dest, plan)
+	refreshParents(ctx, changed, notTeamdrive)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if failed > 0 {
+		return fmt.Errorf("%w: %d operations failed", ErrSyncIncomplete, failed)
+	}
+	return nil
+}
+
+func makeSyncPlan(source, dest string, srcArr, dstArr []*drive.File) syncPlan {
+	srcPaths := relativePaths(source, srcArr)
+	dstPaths := relativePaths(dest, dstArr)
+
+	dstByPath := make(map[string][]*drive.File)
+	for _, i := range dstArr {
+		p := dstPaths[i.Id]
+		dstByPath[p] = append(dstByPath[p], i)
+	}
+
+	plan := syncPlan{mapping: map[string]string{source: dest}}
+	// destination items which are either identical to the source or replaced by an update
+	keep := map[string]bool{dest: true}
+	handled := make(map[string]bool)
+
+	// Parents come before their children once sorted by path, so the kept folders are known
+	// by the time their children are compared. The items having the same path are compared together,
+	// a destination item is matched to one source item at most.
+	srcSorted := sortByPath(srcArr, srcPaths)
+	for n := 0; n < len(srcSorted); {
+		p := srcPaths[srcSorted[n].Id]
+		end := n
+		for end < len(srcSorted) && srcPaths[srcSorted[end].Id] == p {
+			end++
+		}
+		group := srcSorted[n:end]
+		n = end
+
+		var existing []*drive.File
+		for _, j := range dstByPath[p] {
+			if len(j.Parents) > 0 && keep[j.Parents[0]] {
+				existing = append(existing, j)
+			}
+		}
+		taken := func(j *drive.File) bool {
+			return keep[j.Id] || handled[j.Id]
+		}
+
+		var files []*drive.File
+		for _, i := range group {
+			if i.MimeType != FolderType {
+				files = append(files, i)
+				continue
+			}
+			matched := false
+			for _, j := range existing {
+				if j.MimeType == FolderType && !taken(j) {
+					keep[j.Id] = true
+					plan.mapping[i.Id] = j.Id
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				plan.Mkdirs = append(plan.Mkdirs, syncItem{Path: p, File: i})
+			}
+		}
+
+		// the identical files are matched first, so that they are not replaced by a sibling
+		var unmatched []*drive.File
+		for _, i := range files {
+			matched := false
+			for _, j := range existing {
+				if j.MimeType != FolderType && !taken(j) && sameContent(i, j) {
+					keep[j.Id] = true
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				unmatched = append(unmatched, i)
+			}
+		}
+
+		for _, i := range unmatched {
+			var stale []*drive.File
+			for _, j := range existing {
+				if j.MimeType != FolderType && !taken(j) {
+					stale = append(stale, j)
+				}
+			}
+			if len(stale) == 0 {
+				plan.Copies = append(plan.Copies, syncItem{Path: p, File: i})
+				continue
+			}
+			for _, j := range stale {
+				handled[j.Id] = true
+			}
+			plan.Updates = append(plan.Updates, syncItem{Path: p, File: i, Stale: stale})
+		}
+	}
+
+	// Only the topmost extraneous items are trashed, their children go with them.
+	for _, i := range sortByPath(dstArr, dstPaths) {
+		if keep[i.Id] || handled[i.Id] {
+			continue
+		}
+		if len(i.Parents) > 0 && keep[i.Parents[0]] {
+			plan.Trashes = append(plan.Trashes, syncItem{Path: dstPaths[i.Id], File: i})
+		}
+	}
+	return plan
+}
+
+func sameContent(a, b *drive.File) bool {
+	if a.Size != b.Size {
+		return false
+	}
+	return a.Md5Checksum == "" || b.Md5Checksum == "" || a.Md5Checksum == b.Md5Checksum
+}
+
+func sortByPath(arr []*drive.File, paths map[string]string) []*drive.File {
+	sorted := make([]*drive.File, len(arr))
+	copy(sorted, arr)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return paths[sorted[i].Id] < paths[sorted[j].Id]
+	})
+	return sorted
+}
+
+// executeSyncPlan applies the plan and returns the destination folders whose content has changed, along
+// with the number of the operations which have failed.
+func executeSyncPlan(ctx context.Context, dest string, plan syncPlan) (map[string]bool, int) {
+	var mut sync.Mutex
+	var failed int
+	changed := make(map[string]bool)
+	mapping := make(map[string]string, len(plan.mapping))
+	for k, v := range plan.mapping {
+		mapping[k] = v
+	}
+
+	parentOf := func(item *drive.File) (string, bool) {
+		mut.Lock()
+		defer mut.Unlock()
+		if len(item.Parents) == 0 {
+			return dest, true
+		}
+		parent, ok := mapping[item.Parents[0]]
+		return parent, ok
+	}
+
+	pending := make([]*drive.File, 0, len(plan.Mkdirs))
+	for _, i := range plan.Mkdirs {
+		pending = append(pending, i.File)
+	}
+	if len(pending) > 0 {
+		fmt.Printf("\nStart creating folders, total: %d\n", len(pending))
+	}
+	for len(pending) > 0 {
+		var ready, waiting []*drive.File
+		for _, i := range pending {
+			if _, ok := parentOf(i); ok {
+				ready = append(ready, i)
+			} else {
+				waiting = append(waiting, i)
+			}
+		}
+		if len(ready) == 0 {
+			logger.Error("Unable to find the parents of %d folders", len(waiting))
+			failed += len(waiting)
+			break
+		}
+
+		created := processFiles(ctx, ready, status.StatusCreateFolder, func(ctx context.Context, item *drive.File) error {
+			parent, _ := parentOf(item)
+			folder, err := createFolder(ctx, item.Name, []string{parent})
+			if err != nil {
+				return err
+			}
+			mut.Lock()
+			mapping[item.Id] = folder.Id
+			changed[parent] = true
+			mut.Unlock()
+			return nil
+		})
+		failed += len(ready) - len(created)
+		pending = waiting
+	}
+
+	stale := make(map[string][]*drive.File)
+	files := make([]*drive.File, 0, len(plan.Copies)+len(plan.Updates))
+	for _, i := range plan.Copies {
+		files = append(files, i.File)
+	}
+	for _, i := range plan.Updates {
+		files = append(files, i.File)
+		stale[i.File.Id] = i.Stale
+	}
+	if len(files) > 0 {
+		fmt.Printf("\nStarted copying files, total：%d\n", len(files))
+	}
+	copied := processFiles(ctx, files, status.StatusCopy, func(ctx context.Context, item *drive.File) error {
+		parent, ok := parentOf(item)
+		if !ok {
+			logger.Error("No destination folder found for %s", item.Id)
+			return errors.New("no destination folder")
+		}
+		if _, err := copyFile(ctx, item.Id, parent, item.Size); err != nil {
+			logger.Error("Unable to copy %s: %s", item.Id, err)
+			return err
+		}
+		for _, i := range stale[item.Id] {
+			if _, err := trashFile(ctx, i.Id); err != nil {
+				logger.Error("Unable to trash %s: %s", i.Id, err)
+				mut.Lock()
+				failed++
+				mut.Unlock()
+			}
+		}
+		mut.Lock()
+		changed[parent] = true
+		mut.Unlock()
+		return nil
+	})
+	failed += len(files) - len(copied)
+
+	trashes := make([]*drive.File, 0, len(plan.Trashes))
+	for _, i := range plan.Trashes {
+		trashes = append(trashes, i.File)
+	}
+	if len(trashes) > 0 {
+		fmt.Printf("\nStarted trashing items, total：%d\n", len(trashes))
+	}
+	trashed := trashFiles(ctx, trashes)
+	failed += len(trashes) - len(trashed)
+	for k := range parentIDs(trashed) {
+		changed[k] = true
+	}
+	fmt.Println()
+	return changed, failed
+}
@@ -0,0 +1,100 @@
+package gd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/api/drive/v3"
+)
+
+type SyncSuite struct {
+	suite.Suite
+}
+
+func testFolder(id, name, parent string) *drive.File {
+	return &drive.File{Id: id, Name: name, MimeType: FolderType, Parents: []string{parent}}
+}
+
+func testFile(id, name, parent string, size int64, md5 string) *drive.File {
+	return &drive.File{Id: id, Name: name, Size: size, Md5Checksum: md5, Parents: []string{parent}}
+}
+
+func paths(items []syncItem) []string {
+	var p []string
+	for _, i := range items {
+		p = append(p, i.Path)
+	}
+	return p
+}
+
+func (suite *SyncSuite) TestMakeSyncPlan() {
+	src := []*drive.File{
+		testFolder("sa", "a", "src"),
+		testFile("s1", "same.txt", "sa", 1, "x"),
+		testFile("s2", "changed.txt", "sa", 2, "y"),
+		testFile("s3", "new.txt", "sa", 3, "z"),
+		testFolder("sb", "b", "src"),
+		testFile("s4", "inside.txt", "sb", 4, "w"),
+	}
+	dst := []*drive.File{
+		testFolder("da", "a", "dst"),
+		testFile("d1", "same.txt", "da", 1, "x"),
+		testFile("d1dup", "same.txt", "da", 1, "x"),
+		testFile("d2", "changed.txt", "da", 2, "old"),
+		testFile("d3", "extra.txt", "da", 5, "e"),
+		testFolder("dc", "c", "dst"),
+		testFile("d4", "gone.txt", "dc", 6, "g"),
+	}
+
+	plan := makeSyncPlan("src", "dst", src, dst)
+
+	suite.Equal([]string{"b"}, paths(plan.Mkdirs))
+	suite.Equal([]string{"a/new.txt", "b/inside.txt"}, paths(plan.Copies))
+	suite.Equal([]string{"a/changed.txt"}, paths(plan.Updates))
+	suite.Equal("d2", plan.Updates[0].Stale[0].Id)
+	suite.Equal([]string{"a/extra.txt", "a/same.txt", "c"}, paths(plan.Trashes))
+	suite.Equal("d1dup", plan.Trashes[1].File.Id)
+	suite.Equal("da", plan.mapping["sa"])
+}
+
+func (suite *SyncSuite) TestMakeSyncPlanIdentical() {
+	src := []*drive.File{testFolder("sa", "a", "src"), testFile("s1", "f", "sa", 1, "x")}
+	dst := []*drive.File{testFolder("da", "a", "dst"), testFile("d1", "f", "da", 1, "x")}
+
+	suite.True(makeSyncPlan("src", "dst", src, dst).IsEmpty())
+}
+
+func (suite *SyncSuite) TestMakeSyncPlanDuplicateNames() {
+	src := []*drive.File{
+		testFile("s1", "a.txt", "src", 1, "y"),
+		testFile("s2", "a.txt", "src", 1, "x"),
+		testFile("s3", "b.txt", "src", 2, "z"),
+		testFile("s4", "b.txt", "src", 2, "z"),
+		testFolder("s5", "c", "src"),
+		testFolder("s6", "c", "src"),
+		testFile("s7", "d.txt", "src", 3, "new"),
+		testFile("s8", "d.txt", "src", 3, "new2"),
+	}
+	dst := []*drive.File{
+		testFile("d1", "a.txt", "dst", 1, "x"),
+		testFile("d2", "b.txt", "dst", 2, "z"),
+		testFolder("d3", "c", "dst"),
+		testFile("d4", "d.txt", "dst", 3, "old"),
+	}
+
+	plan := makeSyncPlan("src", "dst", src, dst)
+
+	suite.Equal([]string{"c"}, paths(plan.Mkdirs))
+	suite.Equal("s6", plan.Mkdirs[0].File.Id)
+	suite.Equal("d3", plan.mapping["s5"])
+	suite.Equal([]string{"a.txt", "b.txt", "d.txt"}, paths(plan.Copies))
+	suite.Equal([]string{"s1", "s4", "s8"}, []string{plan.Copies[0].File.Id, plan.Copies[1].File.Id, plan.Copies[2].File.Id})
+	suite.Equal([]string{"d.txt"}, paths(plan.Updates))
+	suite.Equal("s7", plan.Updates[0].File.Id)
+	suite.Equal("d4", plan.Updates[0].Stale[0].Id)
+	suite.Empty(plan.Trashes)
+}
+
+func TestSyncSuite(t *testing.T) {
+	suite.Run(t, new(SyncSuite))
+}
@@ -0,0 +1,247 @@
+package gd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+
+	"github.com/xybydy/gdutils/database"
+	"github.com/xybydy/gdutils/logger"
+	"github.com/xybydy/gdutils/summary"
+	"github.com/xybydy/gdutils/utils"
+)
+
+var ErrTaskNotFound = errors.New("task not found")
+
+// TaskList prints the copy tasks recorded in the db.
+func TaskList() error {
+	tasks, err := db.TaskList()
+	if err != nil {
+		return err
+	}
+	if len(tasks) == 0 {
+		fmt.Println("No tasks found")
+		return nil
+	}
+	fmt.Print(summary.MakeTaskTable(tasks))
+	return nil
+}
+
+// TaskShow prints the details of the task.
+func TaskShow(id int) error {
+	task, err := getTask(id)
+	if err != nil {
+		return err
+	}
+	fmt.Print(summary.MakeTaskDetails(task, parseMapping(task.Mapping)))
+	if task.FailedCount == 0 {
+		return nil
+	}
+	failed, err := db.FailedGet(task.ID)
+	if err != nil {
+		return err
+	}
+	fmt.Print("\n", summary.MakeFailedTable(failed))
+	return nil
+}
+
+// TaskResume continues the task from where it is left, the files which are already copied are skipped.
+func TaskResume(ctx context.Context, id int, update, notTeamdrive bool) error {
+	logger.Debugw("Task resume started", "id", id, "update", update, "notTeamdrive", notTeamdrive)
+
+	task, err := getTask(id)
+	if err != nil {
+		return err
+	}
+	release, err := lockTask(task.Source, task.Target, false)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// the task is continued with the options it is started with
+	var index *destIndex
+	if task.MatchMode != "" {
+		index = newDestIndex(task.MatchMode, notTeamdrive)
+	}
+	root, err := resumeTask(ctx, task, task.MinSize, update, notTeamdrive, index)
+	if err != nil {
+		finishTask(ctx, task.ID, "error")
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	fmt.Printf("\nTask %d is finished, destination: https://drive.google.com/drive/folders/%s\n", task.ID, root.Id)
+	return nil
+}
+
+// TaskRetryFailed copies again only the files recorded as failed in the previous runs of the task.
+func TaskRetryFailed(ctx context.Context, id int) error {
+	logger.Debugw("Task retry started", "id", id)
+
+	task, err := getTask(id)
+	if err != nil {
+		return err
+	}
+	release, err := lockTask(task.Source, task.Target, false)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	failed, err := db.FailedGet(task.ID)
+	if err != nil {
+		return err
+	}
+	if len(failed) == 0 {
+		fmt.Printf("Task %d has no failed files\n", task.ID)
+		return nil
+	}
+	mapping, root, err := taskMapping(task)
+	if err != nil {
+		return err
+	}
+
+	err = db.TaskStatusUpdate(task.ID, "copying")
+	if err != nil {
+		logger.Error("", err)
+	}
+	copyFiles(ctx, failedFiles(ctx, task.ID, failed), mapping, root, task.ID, nil)
+
+	remaining, err := db.FailedGet(task.ID)
+	if err != nil {
+		finishTask(ctx, task.ID, "error")
+		return err
+	}
+	if len(remaining) > 0 {
+		finishTask(ctx, task.ID, "error")
+	} else {
+		finishTask(ctx, task.ID, "finished")
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	fmt.Printf("\nFiles retried: %d, still failing: %d\n", len(failed), len(remaining))
+	return nil
+}
+
+// TaskRemove deletes the task and its copied records. The copied files are left untouched. The task is not
+// removed while another process is copying it.
+func TaskRemove(id int) error {
+	task, err := getTask(id)
+	if err != nil {
+		return err
+	}
+	release, err := lockTask(task.Source, task.Target, false)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := db.TaskDeleteByID(task.ID); err != nil {
+		return err
+	}
+	fmt.Printf("Task %d is removed\n", task.ID)
+	return nil
+}
+
+func getTask(id int) (database.TaskDB, error) {
+	task, exists, err := db.TaskGetByID(id)
+	if err != nil {
+		return task, err
+	}
+	if !exists {
+		return task, fmt.Errorf("%w: %d", ErrTaskNotFound, id)
+	}
+	return task, nil
+}
+
+// resumeTask creates the folders missing from the task mapping and copies the files which are not
+// recorded as copied, then returns the destination root of the task.
+func resumeTask(ctx context.Context, task database.TaskDB, minSize int, update, notTeamdrive bool, index *destIndex) (*drive.File, error) {
+	oldMappings, root, err := taskMapping(task)
+	if err != nil {
+		return nil, err
+	}
+
+	copied, err := db.CopiedGet(task.ID)
+	if err != nil {
+		return nil, err
+	}
+	copiedIds := make(map[string]bool)
+	for _, i := range copied {
+		copiedIds[i.FileID] = true
+	}
+
+	logger.Debug("%s - %d", "updating db", task.ID)
+	err = db.TaskStatusUpdate(task.ID, "copying")
+	if err != nil {
+		logger.Error("", err)
+	}
+	copyTree(ctx, task.ID, task.Source, root, minSize, update, notTeamdrive, index, oldMappings, copiedIds)
+	return root, nil
+}
+
+// taskMapping returns the destination folders of the task keyed by the source folder ids, and the destination root.
+func taskMapping(task database.TaskDB) (map[string]*drive.File, *drive.File, error) {
+	logger.Debug("", "Getting mapping from tasks db")
+	pairs := parseMapping(task.Mapping)
+	if len(pairs) == 0 {
+		return nil, nil, fmt.Errorf("task %d has no folder mapping", task.ID)
+	}
+	mapping := make(map[string]*drive.File)
+	for _, i := range pairs {
+		mapping[i[0]] = &drive.File{Id: i[1]}
+	}
+	return mapping, &drive.File{Id: pairs[0][1]}, nil
+}
+
+// failedFiles looks the failed files up in the cache, or in the drive if they are not cached.
+// The files which cannot be found anymore are recorded as failed again.
+func failedFiles(ctx context.Context, taskID int, failed []database.FailedDB) []*drive.File {
+	args := ListArgs{supportsAllDrives: true}
+	args.Fields = []googleapi.Field{"id", "name", "md5Checksum", "mimeType", "size", "parents"}
+
+	files := make([]*drive.File, 0, len(failed))
+	for _, i := range failed {
+		if ctx.Err() != nil {
+			break
+		}
+		cached, exists, err := db.FilesGet(i.FileID)
+		if err != nil {
+			logger.Error("", err)
+		}
+		if exists {
+			files = append(files, cached.ToFile())
+			continue
+		}
+		file, err := fileGetCall(ctx, i.FileID, args)
+		if err != nil {
+			logger.Error("Unable to get the failed file %s: %s", i.FileID, err)
+			if err := db.FailedInsert(taskID, i.FileID, utils.RequestErrorType(err), err.Error()); err != nil {
+				logger.Error("", err)
+			}
+			continue
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// parseMapping splits the task mapping into source and destination folder id pairs, the first pair is the root.
+func parseMapping(mapping string) [][2]string {
+	var pairs [][2]string
+	for _, i := range strings.Split(mapping, "\n") {
+		ids := strings.Fields(i)
+		if len(ids) != 2 {
+			continue
+		}
+		pairs = append(pairs, [2]string{ids[0], ids[1]})
+	}
+	return pairs
+}
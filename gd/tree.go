@@ -0,0 +1,34 @@
+package gd
+
+import (
+	"google.golang.org/api/drive/v3"
+)
+
+// relativePaths returns the paths of the items in the tree relative to root, keyed by item id.
+func relativePaths(root string, arr []*drive.File) map[string]string {
+	byID := make(map[string]*drive.File, len(arr))
+	for _, i := range arr {
+		byID[i.Id] = i
+	}
+
+	paths := make(map[string]string, len(arr))
+	var resolve func(*drive.File, int) string
+	resolve = func(f *drive.File, depth int) string {
+		if p, ok := paths[f.Id]; ok {
+			return p
+		}
+		p := f.Name
+		if len(f.Parents) > 0 && f.Parents[0] != root && depth < len(arr) {
+			if parent, ok := byID[f.Parents[0]]; ok {
+				p = resolve(parent, depth+1) + "/" + f.Name
+			}
+		}
+		paths[f.Id] = p
+		return p
+	}
+
+	for _, i := range arr {
+		resolve(i, 0)
+	}
+	return paths
+}
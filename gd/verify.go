@@ -0,0 +1,115 @@
+package gd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+
+	"github.com/xybydy/gdutils/logger"
+	"github.com/xybydy/gdutils/summary"
+)
+
+// ErrTreesDiffer is returned when the verified trees are not identical.
+var ErrTreesDiffer = errors.New("source and destination trees differ")
+
+// Verify compares the source and the destination trees by relative paths and reports the missing,
+// extra, size mismatched and md5 mismatched items.
+func Verify(ctx context.Context, source, dest, outType, output string, update, notTeamdrive bool) error {
+	logger.Debugw("Verify operation started", "source", source, "dest", dest, "outType", outType, "output", output, "update", update, "notTeamdrive", notTeamdrive)
+	outType = strings.ToLower(outType)
+
+	if !validateFid(source) || !validateFid(dest) {
+		return errors.New("invalid folder id")
+	}
+
+	srcArr, err := walkAndSave(ctx, source, notTeamdrive, update, false)
+	if err != nil {
+		return err
+	}
+	dstArr, err := walkAndSave(ctx, dest, notTeamdrive, true, false)
+	if err != nil {
+		return err
+	}
+
+	report := compareTrees(source, dest, srcArr, dstArr)
+	outStr := summary.GetVerifyOutStr(report, outType)
+	if output != "" {
+		if err := ioutil.WriteFile(output, []byte(outStr), 0666); err != nil {
+			return err
+		}
+		fmt.Printf("\n%s\n", report.String())
+	} else {
+		fmt.Printf("\n%s\n", outStr)
+	}
+	logger.Info("Verify result: %s", report.String())
+
+	if !report.IsEmpty() {
+		return ErrTreesDiffer
+	}
+	return nil
+}
+
+func compareTrees(source, dest string, srcArr, dstArr []*drive.File) summary.VerifyReport {
+	srcPaths := relativePaths(source, srcArr)
+	dstPaths := relativePaths(dest, dstArr)
+	report := summary.VerifyReport{Source: source, Dest: dest, Items: make([]summary.VerifyItem, 0)}
+
+	dstByPath := make(map[string][]*drive.File)
+	for _, i := range dstArr {
+		p := dstPaths[i.Id]
+		dstByPath[p] = append(dstByPath[p], i)
+	}
+
+	matched := make(map[string]bool)
+	for _, i := range sortByPath(srcArr, srcPaths) {
+		p := srcPaths[i.Id]
+		isFolder := i.MimeType == FolderType
+
+		var found *drive.File
+		for _, j := range dstByPath[p] {
+			if !matched[j.Id] && (j.MimeType == FolderType) == isFolder {
+				found = j
+				break
+			}
+		}
+		if found == nil {
+			report.Items = append(report.Items, summary.VerifyItem{
+				Path: p, Status: summary.VerifyMissing,
+				SourceID: i.Id, SourceSize: i.Size, SourceMd5: i.Md5Checksum,
+			})
+			continue
+		}
+		matched[found.Id] = true
+
+		item := summary.VerifyItem{
+			Path: p, SourceID: i.Id, DestID: found.Id,
+			SourceSize: i.Size, DestSize: found.Size, SourceMd5: i.Md5Checksum, DestMd5: found.Md5Checksum,
+		}
+		switch {
+		case isFolder:
+			report.Matched++
+		case i.Size != found.Size:
+			item.Status = summary.VerifySizeMismatch
+			report.Items = append(report.Items, item)
+		case i.Md5Checksum != found.Md5Checksum:
+			item.Status = summary.VerifyMd5Mismatch
+			report.Items = append(report.Items, item)
+		default:
+			report.Matched++
+		}
+	}
+
+	for _, i := range sortByPath(dstArr, dstPaths) {
+		if !matched[i.Id] {
+			report.Items = append(report.Items, summary.VerifyItem{
+				Path: dstPaths[i.Id], Status: summary.VerifyExtra,
+				DestID: i.Id, DestSize: i.Size, DestMd5: i.Md5Checksum,
+			})
+		}
+	}
+	return report
+}
@@ -0,0 +1,46 @@
+package gd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/api/drive/v3"
+
+	"github.com/xybydy/gdutils/summary"
+)
+
+type VerifySuite struct {
+	suite.Suite
+}
+
+func (suite *VerifySuite) TestCompareTrees() {
+	src := []*drive.File{
+		testFolder("sa", "a", "src"),
+		testFile("s1", "ok.txt", "sa", 1, "x"),
+		testFile("s2", "size.txt", "sa", 2, "y"),
+		testFile("s3", "md5.txt", "sa", 3, "z"),
+		testFile("s4", "missing.txt", "sa", 4, "w"),
+	}
+	dst := []*drive.File{
+		testFolder("da", "a", "dst"),
+		testFile("d1", "ok.txt", "da", 1, "x"),
+		testFile("d2", "size.txt", "da", 20, "y"),
+		testFile("d3", "md5.txt", "da", 3, "other"),
+		testFile("d5", "extra.txt", "da", 5, "e"),
+	}
+
+	report := compareTrees("src", "dst", src, dst)
+
+	suite.Equal(2, report.Matched)
+	suite.Equal(map[string]int{
+		summary.VerifyMissing:      1,
+		summary.VerifyExtra:        1,
+		summary.VerifySizeMismatch: 1,
+		summary.VerifyMd5Mismatch:  1,
+	}, report.Counts())
+	suite.True(compareTrees("src", "dst", src[:2], dst[:2]).IsEmpty())
+}
+
+func TestVerifySuite(t *testing.T) {
+	suite.Run(t, new(VerifySuite))
+}
@@ -0,0 +1,146 @@
+package gd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/api/drive/v3"
+
+	"github.com/xybydy/gdutils/database"
+)
+
+type WalkSuite struct {
+	suite.Suite
+}
+
+// SetupTest caches a small tree, so that it can be walked without any requests.
+func (suite *WalkSuite) SetupTest() {
+	db = database.ConnectDB("sqlite3", fmt.Sprintf("file:%s?mode=memory&cache=shared", suite.T().Name()))
+	suite.Require().NoError(db.FilesReplace("root", []database.FileDB{
+		{ID: "sub", Parent: "root", Name: "sub", Mime: FolderType},
+		{ID: "a", Parent: "root", Name: "a", Size: 10},
+	}))
+	suite.Require().NoError(db.FilesReplace("sub", []database.FileDB{
+		{ID: "b", Parent: "sub", Name: "b", Size: 20},
+	}))
+	suite.Require().NoError(db.GDInsertItem("root", `["sub"]`))
+	suite.Require().NoError(db.GDInsertItem("sub", `[]`))
+}
+
+func (suite *WalkSuite) TearDownTest() {
+	suite.NoError(db.Close())
+}
+
+func (suite *WalkSuite) TestWalkCached() {
+	files, err := walkAndSave(context.Background(), "root", false, false, false)
+	suite.NoError(err)
+	suite.Len(files, 3)
+}
+
+func (suite *WalkSuite) TestWalkInterrupted() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	files, err := walkAndSave(ctx, "root", false, false, false)
+	suite.True(errors.Is(err, context.Canceled))
+	suite.Empty(files)
+
+	record, _, err := db.GDGet("root")
+	suite.NoError(err)
+	suite.False(record.ContainsSummary())
+}
+
+func (suite *WalkSuite) TestCacheExpired() {
+	defer SetCachePolicy(0, false)
+
+	suite.False(cacheExpired("root"))
+	SetCachePolicy(time.Hour, false)
+	suite.False(cacheExpired("root"))
+
+	_, err := db.Exec("UPDATE gd SET mtime=? WHERE fid=?", time.Now().Add(-2*time.Hour).Unix(), "root")
+	suite.Require().NoError(err)
+	suite.True(cacheExpired("root"))
+	suite.False(cacheExpired("sub"))
+	suite.False(cacheExpired("missing"))
+}
+
+func (suite *WalkSuite) TestCacheExportImport() {
+	_, err := db.Exec("UPDATE gd SET mtime=?", 1000)
+	suite.Require().NoError(err)
+	path := filepath.Join(suite.T().TempDir(), "tree.json")
+	suite.Require().NoError(CacheExport("root", path))
+
+	suite.Require().NoError(CacheRemove("root"))
+	suite.Error(CacheRemove("root"))
+
+	suite.Require().NoError(CacheImport(path))
+	files, err := getAllByFid("root")
+	suite.NoError(err)
+	suite.Len(files, 3)
+	record, _, err := db.GDGet("sub")
+	suite.NoError(err)
+	suite.Equal(int64(1000), listedAt(record).Unix())
+}
+
+func (suite *WalkSuite) TestPipelineNothingToCopy() {
+	mapping := map[string]*drive.File{"sub": {Id: "dst-sub"}}
+	copied := map[string]bool{"a": true, "b": true}
+
+	p := newCopyPipeline(1, 0, false, false, nil, mapping, copied)
+	suite.True(p.run(context.Background(), "root", &drive.File{Id: "dst"}))
+	suite.Equal(int32(2), p.listed.Get())
+	suite.Equal(int32(0), p.pending.Get())
+}
+
+func (suite *WalkSuite) TestPipelineNext() {
+	ctx := context.Background()
+	p := newCopyPipeline(1, 0, false, false, nil, nil, nil)
+	p.push(
+		pipelineJob{kind: jobList, item: &drive.File{Id: "f1"}},
+		pipelineJob{kind: jobList, item: &drive.File{Id: "f2"}},
+		pipelineJob{kind: jobCopy, item: &drive.File{Id: "a"}},
+	)
+
+	job, ok := p.next(ctx)
+	suite.True(ok)
+	suite.Equal("f2", job.item.Id, "the folders are taken first, the last pushed one first")
+
+	for i := 0; i < maxQueuedFiles; i++ {
+		p.push(pipelineJob{kind: jobCopy, item: &drive.File{Id: fmt.Sprint(i)}})
+	}
+	job, ok = p.next(ctx)
+	suite.True(ok)
+	suite.Equal("a", job.item.Id, "no more folders are listed once enough files are queued")
+}
+
+func (suite *WalkSuite) TestDriveTree() {
+	items := []*drive.File{
+		{Id: "b", Name: "b", Parents: []string{"sub"}},
+		{Id: "sub", Name: "sub", MimeType: FolderType, Parents: []string{"drive"}},
+		{Id: "empty", Name: "empty", MimeType: FolderType, Parents: []string{"sub"}},
+		{Id: "a", Name: "a", Parents: []string{"drive"}},
+		{Id: "orphan", Name: "orphan", Parents: []string{"elsewhere"}},
+	}
+
+	folders, result := driveTree("drive", items, 0)
+	suite.Len(result, 4)
+	suite.Len(folders, 3)
+	suite.Equal("drive", folders[0].Fid)
+	suite.Equal(`["sub"]`, folders[0].Subf)
+	suite.Equal("empty", folders[2].Fid)
+	suite.Equal(`[]`, folders[2].Subf)
+
+	suite.NoError(db.FilesReplaceFolders(folders))
+	files, err := walkAndSave(context.Background(), "drive", false, false, false)
+	suite.NoError(err)
+	suite.Len(files, 4)
+}
+
+func TestWalkSuite(t *testing.T) {
+	suite.Run(t, new(WalkSuite))
+}
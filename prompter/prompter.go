@@ -1,6 +1,8 @@
 package prompter
 
 import (
+	"fmt"
+
 	"github.com/manifoldco/promptui"
 )
 
@@ -35,6 +37,14 @@ var yesOrNo = []struct {
 	{"No", "Do not delete"},
 }
 
+var takeoverChoices = []struct {
+	Name        string
+	Description string
+}{
+	{"Yes", "Take over the task"},
+	{"No", "Exit"},
+}
+
 var PromptUserChoice = promptui.Select{
 	Label:        "Do you wish to continue",
 	Items:        userChoices,
@@ -44,9 +54,50 @@ var PromptUserChoice = promptui.Select{
 }
 
 var promptDuplicate = promptui.Select{
-	Label:        `Duplicate files detected ${file_number}，Empty Folders detected${folder_number}，Delete them?`,
+	Label:        "Duplicate files detected %d, Empty folders detected %d, Delete them",
 	Items:        yesOrNo,
 	HideHelp:     true,
 	HideSelected: true,
 	Templates:    selectTemplate,
 }
+
+// PromptDuplicate asks the user whether the found duplicate files and empty folders should be trashed.
+func PromptDuplicate(fileNum, folderNum int) (bool, error) {
+	prompt := promptDuplicate
+	prompt.Label = fmt.Sprintf(promptDuplicate.Label.(string), fileNum, folderNum)
+
+	choice, _, err := prompt.Run()
+	if err != nil {
+		return false, err
+	}
+	return choice == optionYes, nil
+}
+
+// PromptTrash asks the user whether the given number of items should be trashed.
+func PromptTrash(num int) (bool, error) {
+	prompt := promptDuplicate
+	prompt.Label = fmt.Sprintf("%d items will be trashed at the destination, Continue", num)
+
+	choice, _, err := prompt.Run()
+	if err != nil {
+		return false, err
+	}
+	return choice == optionYes, nil
+}
+
+// PromptTakeover asks the user whether the task held by a process which seems to be dead should be taken over.
+func PromptTakeover(owner string) (bool, error) {
+	prompt := promptui.Select{
+		Label:        fmt.Sprintf("The task is locked by %s which has stopped responding, Take it over", owner),
+		Items:        takeoverChoices,
+		HideHelp:     true,
+		HideSelected: true,
+		Templates:    selectTemplate,
+	}
+
+	choice, _, err := prompt.Run()
+	if err != nil {
+		return false, err
+	}
+	return choice == optionYes, nil
+}
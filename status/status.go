@@ -12,6 +12,7 @@ const (
 	StatusReadPath = iota
 	StatusCopy
 	StatusCreateFolder
+	StatusTrash
 )
 
 func PrintStatus(ctx context.Context, pending *counter.Counter, done *counter.Counter, statusType int) {
@@ -24,6 +25,8 @@ func PrintStatus(ctx context.Context, pending *counter.Counter, done *counter.Co
 		printText = "%s | Files Copied: %d | Files Pending: %d |"
 	case StatusCreateFolder:
 		printText = "%s | Folders Created %d | Folders Pending %d |"
+	case StatusTrash:
+		printText = "%s | Items Trashed %d | Items Pending %d |"
 	}
 
 	ticker := time.NewTicker(500 * time.Millisecond)
@@ -43,6 +46,25 @@ func PrintStatus(ctx context.Context, pending *counter.Counter, done *counter.Co
 	}
 }
 
+// PrintCopyStatus prints the progress of a copy which lists the folders and copies the files at the same time.
+func PrintCopyStatus(ctx context.Context, folders, copied, pending *counter.Counter) {
+	printText := "%s | Folders Read: %d | Files Copied: %d | Files Pending: %d |"
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			now := time.Now().Format("15:04:05")
+			printProgress(fmt.Sprintf(printText, now, folders.Get(), copied.Get(), pending.Get()))
+			return
+		case <-ticker.C:
+			now := time.Now().Format("15:04:05")
+			printProgress(fmt.Sprintf(printText, now, folders.Get(), copied.Get(), pending.Get()))
+		}
+	}
+}
+
 func printProgress(msg string) {
 	fmt.Printf("\r\033[K%s", msg)
 }
@@ -0,0 +1,28 @@
+package summary
+
+import (
+	"bytes"
+
+	"github.com/olekukonko/tablewriter"
+
+	"github.com/xybydy/gdutils/database"
+)
+
+func MakeBookmarkTable(bookmarks []database.BookmarkDB) string {
+	buf := new(bytes.Buffer)
+
+	table := tablewriter.NewWriter(buf)
+	table.SetHeader([]string{"Name", "Folder ID"})
+	table.SetHeaderColor(
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+	)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+
+	for _, i := range bookmarks {
+		table.Append([]string{i.Alias, i.Target})
+	}
+	table.Render()
+	return buf.String()
+}
@@ -0,0 +1,47 @@
+package summary
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+
+	"github.com/xybydy/gdutils/database"
+)
+
+// MakeCacheStats lists the row counts and the size of the db, followed by the oldest cached folders.
+func MakeCacheStats(stats database.CacheStats, oldest []database.CacheEntry) string {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "Folders:         %d\n", stats.Folders)
+	fmt.Fprintf(buf, "Items:           %d\n", stats.Files)
+	fmt.Fprintf(buf, "Hashes:          %d\n", stats.Hashes)
+	fmt.Fprintf(buf, "Tasks:           %d\n", stats.Tasks)
+	fmt.Fprintf(buf, "Tracked drives:  %d\n", stats.Tracked)
+	fmt.Fprintf(buf, "Size:            %s\n", formatSize(float64(stats.Size)))
+	if len(oldest) == 0 {
+		return buf.String()
+	}
+
+	buf.WriteString("\nOldest folders:\n")
+	table := tablewriter.NewWriter(buf)
+	table.SetHeader([]string{"Folder ID", "Listed", "Items"})
+	table.SetHeaderColor(
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+	)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+
+	for _, i := range oldest {
+		table.Append([]string{
+			i.Fid,
+			formatUnix(sql.NullInt64{Int64: i.ListedAt, Valid: true}),
+			strconv.Itoa(i.Items),
+		})
+	}
+	table.Render()
+	return buf.String()
+}
@@ -0,0 +1,120 @@
+package summary
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// DuItem is the total usage of a folder including its sub folders.
+type DuItem struct {
+	ID      string
+	Path    string
+	Depth   int
+	Files   int
+	Folders int
+	Size    int64
+}
+
+// FilterDu keeps the folders not deeper than depth, sorts them and returns the first top of them.
+// The first item is always the root, which is used as the total.
+func FilterDu(items []DuItem, depth, top int, sortBy string) []DuItem {
+	if len(items) == 0 {
+		return items
+	}
+	root := items[0]
+	filtered := make([]DuItem, 0)
+	for _, i := range items[1:] {
+		if depth < 0 || i.Depth <= depth {
+			filtered = append(filtered, i)
+		}
+	}
+
+	switch sortBy {
+	case "name":
+		sort.SliceStable(filtered, func(i, j int) bool {
+			return filtered[i].Path < filtered[j].Path
+		})
+	case "count":
+		sort.SliceStable(filtered, func(i, j int) bool {
+			return filtered[i].Files > filtered[j].Files
+		})
+	default:
+		sort.SliceStable(filtered, func(i, j int) bool {
+			return filtered[i].Size > filtered[j].Size
+		})
+	}
+
+	if top > 0 && len(filtered) > top {
+		filtered = filtered[:top]
+	}
+	return append([]DuItem{root}, filtered...)
+}
+
+func duRow(i DuItem, total int64) []string {
+	share := "0.00%"
+	if total > 0 {
+		share = fmt.Sprintf("%.2f%%", float64(i.Size)*100/float64(total))
+	}
+	return []string{i.Path, strconv.Itoa(i.Files), strconv.Itoa(i.Folders), formatSize(float64(i.Size)), share}
+}
+
+func MakeDuTable(items []DuItem) string {
+	buf := new(bytes.Buffer)
+	if len(items) == 0 {
+		return ""
+	}
+	root := items[0]
+
+	table := tablewriter.NewWriter(buf)
+	table.SetHeader([]string{"Folder", "Files", "Folders", "Size", "Share"})
+	table.SetHeaderColor(
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+	)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetFooterAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+
+	table.SetFooter(duRow(root, root.Size))
+	table.SetFooterColor(
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+	)
+	for _, i := range items[1:] {
+		table.Append(duRow(i, root.Size))
+	}
+	table.Render()
+	return buf.String()
+}
+
+func MakeDuHTML(items []DuItem) string {
+	if len(items) == 0 {
+		return ""
+	}
+	root := items[0]
+
+	head := []string{"Folder", "Files", "Folders", "Size", "Share"}
+	th := fmt.Sprintf("<tr>%s</tr>", putBetween(head, "th"))
+	td := make([]string, 0)
+	for _, i := range items[1:] {
+		td = append(td, fmt.Sprintf("<tr>%s</tr>", putBetween(duRow(i, root.Size), "td")))
+	}
+	tail := fmt.Sprintf(`<tr style="font-weight:bold">%s</tr>`, putBetween(duRow(root, root.Size), "td"))
+
+	return fmt.Sprintf(`<table border="1" cellpadding="12" style="border-collapse:collapse;font-family:serif;font-size:22px;margin:10px auto;text-align: center">
+    %s
+    %s
+    %s
+  </table>`, th, strings.Join(td, ""), tail)
+}
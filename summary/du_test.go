@@ -0,0 +1,26 @@
+package summary
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type DuSuite struct {
+	suite.Suite
+}
+
+func (suite *DuSuite) TestMakeDuHTMLEscapes() {
+	items := []DuItem{
+		{ID: "root", Path: "root", Size: 20},
+		{ID: "f1", Path: "root/<script>alert(1)</script>", Size: 10, Depth: 1},
+	}
+
+	out := MakeDuHTML(items)
+	suite.NotContains(out, "<script>")
+	suite.Contains(out, "&lt;script&gt;alert(1)&lt;/script&gt;")
+}
+
+func TestDuSuite(t *testing.T) {
+	suite.Run(t, new(DuSuite))
+}
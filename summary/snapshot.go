@@ -0,0 +1,42 @@
+package summary
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+
+	"github.com/xybydy/gdutils/database"
+)
+
+// Snapshot is the state of a folder at a point in time, it can be stored and compared with diff later.
+type Snapshot struct {
+	Root    string
+	Time    time.Time
+	Summary database.GdDBSummary
+	Files   []*drive.File
+}
+
+func MakeSnapshot(root string, info []*drive.File) Snapshot {
+	files := info
+	if files == nil {
+		files = make([]*drive.File, 0)
+	}
+	return Snapshot{
+		Root:    root,
+		Time:    time.Now().UTC(),
+		Summary: Summary(info, ""),
+		Files:   files,
+	}
+}
+
+func ReadSnapshot(path string) (Snapshot, error) {
+	var s Snapshot
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	err = json.Unmarshal(content, &s)
+	return s, err
+}
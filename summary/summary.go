@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"html"
 	"log"
 	"path/filepath"
 	"sort"
@@ -16,10 +17,11 @@ import (
 	"github.com/xybydy/gdutils/database"
 )
 
+// putBetween wraps each item in the container tag. The items are escaped, they may hold the names of the files.
 func putBetween(item []string, container string) string {
 	retStrings := make([]string, 0)
 	for _, i := range item {
-		retStrings = append(retStrings, fmt.Sprintf("<%s>%s</%s>", container, i, container))
+		retStrings = append(retStrings, fmt.Sprintf("<%s>%s</%s>", container, html.EscapeString(i), container))
 	}
 	return strings.Join(retStrings, "")
 }
@@ -81,7 +83,7 @@ func Summary(info []*drive.File, sortBy string) database.GdDBSummary {
 	files := func() []*drive.File {
 		var f []*drive.File
 		for _, i := range info {
-			if i.MimeType != "application/vnd.google-apps.folder" {
+			if i.MimeType != folderType {
 				f = append(f, i)
 			}
 		}
@@ -92,7 +94,7 @@ func Summary(info []*drive.File, sortBy string) database.GdDBSummary {
 	folders := func() []*drive.File {
 		var f []*drive.File
 		for _, i := range info {
-			if i.MimeType == "application/vnd.google-apps.folder" {
+			if i.MimeType == folderType {
 				f = append(f, i)
 			}
 		}
@@ -202,12 +204,20 @@ func formatSize(n float64) string {
 	return fmt.Sprintf("%.2f %s", n, units[flag])
 }
 
-func GetOutStr(info []*drive.File, outType, sort string) string {
+func GetOutStr(root string, info []*drive.File, outType, sort string) string {
 	smy := Summary(info, sort)
 	var outStr string
 	switch outType {
 	case "html":
 		outStr = MakeHTML(smy)
+	case "tree":
+		outStr = MakeTree(root, info, sort)
+	case "snap":
+		js, err := json.MarshalIndent(MakeSnapshot(root, info), "", "  ")
+		if err != nil {
+			log.Panic(err)
+		}
+		outStr = string(js)
 	case "json":
 		js, err := json.MarshalIndent(smy, "", "  ")
 		if err != nil {
@@ -0,0 +1,112 @@
+package summary
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+
+	"github.com/xybydy/gdutils/database"
+	"github.com/xybydy/gdutils/utils"
+)
+
+const timeLayout = "2006-01-02 15:04:05"
+
+func MakeTaskTable(tasks []database.TaskDB) string {
+	buf := new(bytes.Buffer)
+
+	table := tablewriter.NewWriter(buf)
+	table.SetHeader([]string{"ID", "Source", "Target", "Status", "Started", "Finished", "Copied", "Failed"})
+	table.SetHeaderColor(
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+	)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+
+	for _, i := range tasks {
+		table.Append([]string{
+			strconv.Itoa(i.ID),
+			i.Source,
+			i.Target,
+			i.Status,
+			formatUnix(i.Ctime),
+			formatUnix(i.Ftime),
+			strconv.Itoa(i.CopiedCount),
+			strconv.Itoa(i.FailedCount),
+		})
+	}
+	table.Render()
+	return buf.String()
+}
+
+// MakeTaskDetails lists the fields of the task, mapping is the source and destination folder pairs of it.
+func MakeTaskDetails(task database.TaskDB, mapping [][2]string) string {
+	buf := new(bytes.Buffer)
+	destination := ""
+	if len(mapping) > 0 {
+		destination = mapping[0][1]
+	}
+
+	fmt.Fprintf(buf, "ID:             %d\n", task.ID)
+	fmt.Fprintf(buf, "Source:         %s\n", task.Source)
+	fmt.Fprintf(buf, "Target:         %s\n", task.Target)
+	fmt.Fprintf(buf, "Destination:    %s\n", destination)
+	fmt.Fprintf(buf, "Status:         %s\n", task.Status)
+	if task.MinSize > 0 {
+		fmt.Fprintf(buf, "Min size:       %d\n", task.MinSize)
+	}
+	if task.MatchMode != "" {
+		fmt.Fprintf(buf, "Incremental:    %s\n", task.MatchMode)
+	}
+	fmt.Fprintf(buf, "Started:        %s\n", formatUnix(task.Ctime))
+	fmt.Fprintf(buf, "Finished:       %s\n", formatUnix(task.Ftime))
+	fmt.Fprintf(buf, "Folders mapped: %d\n", len(mapping))
+	fmt.Fprintf(buf, "Files copied:   %d\n", task.CopiedCount)
+	fmt.Fprintf(buf, "Files failed:   %d\n", task.FailedCount)
+	return buf.String()
+}
+
+func MakeFailedTable(failed []database.FailedDB) string {
+	buf := new(bytes.Buffer)
+
+	table := tablewriter.NewWriter(buf)
+	table.SetHeader([]string{"File ID", "Error", "Attempts", "Last Attempt", "Message"})
+	table.SetHeaderColor(
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+	)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+
+	for _, i := range failed {
+		table.Append([]string{
+			i.FileID,
+			utils.RequestErrorName(i.Class),
+			strconv.Itoa(i.Attempts),
+			formatUnix(i.Mtime),
+			i.Message,
+		})
+	}
+	table.Render()
+	return buf.String()
+}
+
+func formatUnix(t sql.NullInt64) string {
+	if !t.Valid || t.Int64 == 0 {
+		return "-"
+	}
+	return time.Unix(t.Int64, 0).Format(timeLayout)
+}
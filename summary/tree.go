@@ -0,0 +1,95 @@
+package summary
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+)
+
+const folderType = "application/vnd.google-apps.folder"
+
+type usage struct {
+	files int
+	size  int64
+}
+
+// MakeTree renders the hierarchy under root with the number of files and the total size of each folder,
+// similar to tree -h --du.
+func MakeTree(root string, info []*drive.File, sortBy string) string {
+	children := make(map[string][]*drive.File)
+	for _, i := range info {
+		if len(i.Parents) > 0 {
+			children[i.Parents[0]] = append(children[i.Parents[0]], i)
+		}
+	}
+
+	usages := make(map[string]usage)
+	var du func(string) usage
+	du = func(id string) usage {
+		if u, ok := usages[id]; ok {
+			return u
+		}
+		usages[id] = usage{}
+		var u usage
+		for _, c := range children[id] {
+			if c.MimeType == folderType {
+				cu := du(c.Id)
+				u.files += cu.files
+				u.size += cu.size
+			} else {
+				u.files++
+				u.size += c.Size
+			}
+		}
+		usages[id] = u
+		return u
+	}
+
+	var b strings.Builder
+	var dirs, files int
+	var render func(string, string)
+	render = func(id, prefix string) {
+		items := children[id]
+		sort.SliceStable(items, func(i, j int) bool {
+			fi, fj := items[i].MimeType == folderType, items[j].MimeType == folderType
+			if fi != fj {
+				return fi
+			}
+			if sortBy == "size" {
+				return itemSize(items[i], du) > itemSize(items[j], du)
+			}
+			return items[i].Name < items[j].Name
+		})
+
+		for idx, c := range items {
+			branch, next := "├── ", "│   "
+			if idx == len(items)-1 {
+				branch, next = "└── ", "    "
+			}
+			if c.MimeType == folderType {
+				dirs++
+				u := du(c.Id)
+				fmt.Fprintf(&b, "%s%s%s/ [%s, %d files]\n", prefix, branch, c.Name, formatSize(float64(u.size)), u.files)
+				render(c.Id, prefix+next)
+				continue
+			}
+			files++
+			fmt.Fprintf(&b, "%s%s%s [%s]\n", prefix, branch, c.Name, formatSize(float64(c.Size)))
+		}
+	}
+
+	u := du(root)
+	fmt.Fprintf(&b, "%s/ [%s, %d files]\n", root, formatSize(float64(u.size)), u.files)
+	render(root, "")
+	fmt.Fprintf(&b, "\n%d directories, %d files\n", dirs, files)
+	return b.String()
+}
+
+func itemSize(f *drive.File, du func(string) usage) int64 {
+	if f.MimeType == folderType {
+		return du(f.Id).size
+	}
+	return f.Size
+}
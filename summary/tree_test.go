@@ -0,0 +1,35 @@
+package summary
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/api/drive/v3"
+)
+
+type TreeSuite struct {
+	suite.Suite
+}
+
+func (suite *TreeSuite) TestMakeTree() {
+	info := []*drive.File{
+		{Id: "f1", Name: "docs", MimeType: folderType, Parents: []string{"root"}},
+		{Id: "1", Name: "b.txt", Size: 1024, Parents: []string{"f1"}},
+		{Id: "2", Name: "a.txt", Size: 1024, Parents: []string{"f1"}},
+		{Id: "3", Name: "top.txt", Size: 10, Parents: []string{"root"}},
+	}
+
+	want := `root/ [2.01 KB, 3 files]
+├── docs/ [2.00 KB, 2 files]
+│   ├── a.txt [1.00 KB]
+│   └── b.txt [1.00 KB]
+└── top.txt [10.00 B]
+
+1 directories, 3 files
+`
+	suite.Equal(want, MakeTree("root", info, ""))
+}
+
+func TestTreeSuite(t *testing.T) {
+	suite.Run(t, new(TreeSuite))
+}
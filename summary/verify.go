@@ -0,0 +1,119 @@
+package summary
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+const (
+	VerifyMissing      = "missing"
+	VerifyExtra        = "extra"
+	VerifySizeMismatch = "size mismatch"
+	VerifyMd5Mismatch  = "md5 mismatch"
+)
+
+// VerifyItem is a difference found between the source and the destination trees.
+type VerifyItem struct {
+	Path       string
+	Status     string
+	SourceID   string `json:",omitempty"`
+	DestID     string `json:",omitempty"`
+	SourceSize int64  `json:",omitempty"`
+	DestSize   int64  `json:",omitempty"`
+	SourceMd5  string `json:",omitempty"`
+	DestMd5    string `json:",omitempty"`
+}
+
+type VerifyReport struct {
+	Source  string
+	Dest    string
+	Matched int
+	Items   []VerifyItem
+}
+
+func (r VerifyReport) IsEmpty() bool {
+	return len(r.Items) == 0
+}
+
+// Counts returns the number of the differences by status.
+func (r VerifyReport) Counts() map[string]int {
+	counts := make(map[string]int)
+	for _, i := range r.Items {
+		counts[i.Status]++
+	}
+	return counts
+}
+
+func (r VerifyReport) String() string {
+	counts := r.Counts()
+	return fmt.Sprintf("Matched: %d, Missing: %d, Extra: %d, Size mismatch: %d, Md5 mismatch: %d",
+		r.Matched, counts[VerifyMissing], counts[VerifyExtra], counts[VerifySizeMismatch], counts[VerifyMd5Mismatch])
+}
+
+func MakeVerifyTable(r VerifyReport) string {
+	buf := new(bytes.Buffer)
+	table := tablewriter.NewWriter(buf)
+	table.SetHeader([]string{"Status", "Path", "Source Size", "Dest Size"})
+	table.SetHeaderColor(
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiBlueColor},
+	)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+
+	for _, i := range r.Items {
+		table.Append([]string{i.Status, i.Path, verifySize(i.SourceID, i.SourceSize), verifySize(i.DestID, i.DestSize)})
+	}
+	table.Render()
+	return buf.String() + r.String() + "\n"
+}
+
+func MakeVerifyCSV(r VerifyReport) string {
+	buf := new(bytes.Buffer)
+	w := csv.NewWriter(buf)
+	err := w.Write([]string{"status", "path", "source_id", "dest_id", "source_size", "dest_size", "source_md5", "dest_md5"})
+	if err != nil {
+		log.Panic(err)
+	}
+	for _, i := range r.Items {
+		err := w.Write([]string{i.Status, i.Path, i.SourceID, i.DestID, strconv.FormatInt(i.SourceSize, 10),
+			strconv.FormatInt(i.DestSize, 10), i.SourceMd5, i.DestMd5})
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+	w.Flush()
+	return buf.String()
+}
+
+func GetVerifyOutStr(r VerifyReport, outType string) string {
+	var outStr string
+	switch outType {
+	case "json":
+		js, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			log.Panic(err)
+		}
+		outStr = string(js)
+	case "csv":
+		outStr = MakeVerifyCSV(r)
+	default:
+		outStr = MakeVerifyTable(r)
+	}
+	return outStr
+}
+
+func verifySize(id string, size int64) string {
+	if id == "" {
+		return "-"
+	}
+	return formatSize(float64(size))
+}
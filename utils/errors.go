@@ -7,6 +7,7 @@ import (
 	"github.com/xybydy/gdutils/logger"
 
 	"golang.org/x/net/context"
+	"golang.org/x/oauth2"
 	"google.golang.org/api/googleapi"
 )
 
@@ -17,8 +18,43 @@ const (
 	RequestTimeoutError
 	RequestBadRequest
 	RequestUnknownError
+	// The classes below are appended last, the classes are stored in the failed records of the tasks
+	RequestAuthError
+	// RequestForbiddenError is a 403 other than a rate limit, such as insufficientFilePermissions or
+	// cannotCopyFile. It is permanent for the file, retrying with another account does not help.
+	RequestForbiddenError
 )
 
+// rateLimitReasons are the reasons of the 403 and 429 errors which are temporary.
+var rateLimitReasons = map[string]bool{
+	"userRateLimitExceeded":    true,
+	"rateLimitExceeded":        true,
+	"sharingRateLimitExceeded": true,
+	"dailyLimitExceeded":       true,
+}
+
+// RequestErrorName returns a readable name for the class returned by RequestErrorType.
+func RequestErrorName(class int) string {
+	switch class {
+	case RequestBackendError:
+		return "backend"
+	case RequestRateLimitError:
+		return "rate limit"
+	case RequestNotFoundError:
+		return "not found"
+	case RequestTimeoutError:
+		return "timeout"
+	case RequestBadRequest:
+		return "bad request"
+	case RequestAuthError:
+		return "auth"
+	case RequestForbiddenError:
+		return "forbidden"
+	default:
+		return "unknown"
+	}
+}
+
 func IsRateLimitError(err error) bool {
 	return RequestErrorType(err) == RequestRateLimitError
 }
@@ -27,6 +63,21 @@ func IsBackendError(err error) bool {
 	return RequestErrorType(err) == RequestBackendError
 }
 
+// IsAuthError reports whether the credentials of the request are rejected, such as a 401 response
+// or a token which cannot be obtained because of invalid_grant.
+func IsAuthError(err error) bool {
+	return RequestErrorType(err) == RequestAuthError
+}
+
+// RequestErrorReason returns the reason of the api error, such as userRateLimitExceeded or dailyLimitExceeded.
+func RequestErrorReason(err error) string {
+	var ae *googleapi.Error
+	if errors.As(err, &ae) && len(ae.Errors) > 0 {
+		return ae.Errors[0].Reason
+	}
+	return ""
+}
+
 func RequestErrorType(err error) int {
 	logger.Error("API Request error: %s", err)
 
@@ -42,13 +93,24 @@ func RequestErrorType(err error) int {
 			return RequestBackendError
 		case ae.Code == 400:
 			return RequestBadRequest
-		case ae.Code == 403:
+		case ae.Code == 401:
+			return RequestAuthError
+		case ae.Code == 429:
 			return RequestRateLimitError
+		case ae.Code == 403 && rateLimitReasons[RequestErrorReason(err)]:
+			return RequestRateLimitError
+		case ae.Code == 403:
+			return RequestForbiddenError
 		case ae.Code == 404:
 			return RequestNotFoundError
 		}
 	}
 
+	var re *oauth2.RetrieveError
+	if errors.As(err, &re) && re.Response != nil && re.Response.StatusCode >= 400 && re.Response.StatusCode <= 499 {
+		return RequestAuthError
+	}
+
 	if errors.Is(err, context.Canceled) {
 		return RequestTimeoutError
 	}
@@ -1,7 +1,11 @@
 package utils
 
 import (
+	"fmt"
 	"math"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/xybydy/gdutils/logger"
 )
@@ -16,3 +20,46 @@ func Pow(x int, y int) int {
 	f := math.Pow(float64(x), float64(y))
 	return int(f)
 }
+
+var sizeUnits = []string{"b", "kb", "mb", "gb", "tb", "pb"}
+
+// ParseSize converts human readable sizes such as 10mb or 1.5gb into bytes. The value must end with b.
+func ParseSize(size string) (int64, error) {
+	size = strings.ToLower(strings.TrimSpace(size))
+	if size == "" {
+		return 0, nil
+	}
+
+	for i := len(sizeUnits) - 1; i >= 0; i-- {
+		if !strings.HasSuffix(size, sizeUnits[i]) {
+			continue
+		}
+		n, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(size, sizeUnits[i])), 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid size: %q", size)
+		}
+		return int64(n * float64(Pow(1024, i))), nil
+	}
+	return 0, fmt.Errorf("invalid size: %q, it must end with b, such as 10mb", size)
+}
+
+// ParseAge converts ages such as 30d, 12h or 90m into a duration. Days are accepted in addition to the
+// units of time.ParseDuration, an empty age is zero.
+func ParseAge(age string) (time.Duration, error) {
+	age = strings.ToLower(strings.TrimSpace(age))
+	if age == "" {
+		return 0, nil
+	}
+	if strings.HasSuffix(age, "d") {
+		n, err := strconv.ParseFloat(strings.TrimSuffix(age, "d"), 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age: %q", age)
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	d, err := time.ParseDuration(age)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age: %q, such as 30d or 12h", age)
+	}
+	return d, nil
+}
@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+)
+
+type UtilsSuite struct {
+	suite.Suite
+}
+
+func (suite *UtilsSuite) TestParseSize() {
+	tests := []struct {
+		name    string
+		give    string
+		want    int64
+		wantErr bool
+	}{
+		{"empty", "", 0, false},
+		{"bytes", "100b", 100, false},
+		{"kilobytes", "1kb", 1024, false},
+		{"megabytes", "10mb", 10 * 1024 * 1024, false},
+		{"upper case", "2GB", 2 * 1024 * 1024 * 1024, false},
+		{"fraction", "1.5kb", 1536, false},
+		{"no unit", "100", 0, true},
+		{"unknown unit", "10xb", 0, true},
+		{"negative", "-1mb", 0, true},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			got, err := ParseSize(tt.give)
+			if tt.wantErr {
+				suite.Error(err)
+				return
+			}
+			suite.NoError(err)
+			suite.Equal(tt.want, got)
+		})
+	}
+}
+
+func (suite *UtilsSuite) TestParseAge() {
+	tests := []struct {
+		name    string
+		give    string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"empty", "", 0, false},
+		{"days", "30d", 30 * 24 * time.Hour, false},
+		{"hours", "12h", 12 * time.Hour, false},
+		{"upper case", "2D", 48 * time.Hour, false},
+		{"no unit", "10", 0, true},
+		{"negative", "-1d", 0, true},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			got, err := ParseAge(tt.give)
+			if tt.wantErr {
+				suite.Error(err)
+				return
+			}
+			suite.NoError(err)
+			suite.Equal(tt.want, got)
+		})
+	}
+}
+
+func (suite *UtilsSuite) TestRequestErrorType() {
+	grant := &oauth2.RetrieveError{Response: &http.Response{StatusCode: 400}, Body: []byte(`{"error":"invalid_grant"}`)}
+	tests := []struct {
+		name string
+		give error
+		want int
+	}{
+		{"backend", &googleapi.Error{Code: 503}, RequestBackendError},
+		{"rate limit", &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "userRateLimitExceeded"}}}, RequestRateLimitError},
+		{"daily limit", &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "dailyLimitExceeded"}}}, RequestRateLimitError},
+		{"too many requests", &googleapi.Error{Code: 429}, RequestRateLimitError},
+		{"no permission", &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "insufficientFilePermissions"}}}, RequestForbiddenError},
+		{"cannot copy", &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "cannotCopyFile"}}}, RequestForbiddenError},
+		{"unauthorized", &googleapi.Error{Code: 401}, RequestAuthError},
+		{"invalid grant", &url.Error{Op: "Post", URL: "https://oauth2.googleapis.com/token", Err: grant}, RequestAuthError},
+		{"wrapped", fmt.Errorf("no chance to file copy call: %w", &googleapi.Error{Code: 503}), RequestBackendError},
+		{"unknown", fmt.Errorf("unknown"), RequestUnknownError},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			suite.Equal(tt.want, RequestErrorType(tt.give))
+		})
+	}
+}
+
+func TestUtilsSuite(t *testing.T) {
+	suite.Run(t, new(UtilsSuite))
+}